@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -553,6 +554,100 @@ func handleCommands() *exrouter.Route {
 		}
 	}).Cat("Admin").Alias("catalog", "cache").Desc("Catalogs history for this channel")
 
+	go router.On("failures", func(ctx *exrouter.Context) {
+		if isCommandableChannel(ctx.Msg) {
+			if !hasPerms(ctx.Msg.ChannelID, discordgo.PermissionSendMessages) {
+				log.Println(lg("Command", "Failures", color.HiRedString, fmtBotSendPerm, ctx.Msg.ChannelID))
+			} else if !isBotAdmin(ctx.Msg) {
+				if _, err := replyEmbed(ctx.Msg, "Command — Failures", cmderrLackingBotAdminPerms); err != nil {
+					log.Println(lg("Command", "Failures", color.HiRedString,
+						cmderrSendFailure, getUserIdentifier(*ctx.Msg.Author), err))
+				}
+				log.Println(lg("Command", "Failures", color.HiCyanString,
+					"%s tried to manage failed downloads but lacked bot admin perms.", getUserIdentifier(*ctx.Msg.Author)))
+			} else {
+				subcommand := strings.ToLower(ctx.Args.Get(1))
+				target := strings.ToLower(ctx.Args.Get(2))
+
+				// Targets, "all" or a list of IDs
+				failures := dbGetFailures()
+				var targets []*downloadFailure
+				if target == "all" {
+					targets = failures
+				} else if target != "" {
+					for _, id := range strings.Split(target, ",") {
+						for _, failure := range failures {
+							if strconv.Itoa(failure.ID) == strings.TrimSpace(id) {
+								targets = append(targets, failure)
+							}
+						}
+					}
+				}
+
+				var content string
+				switch subcommand {
+				case "retry":
+					if len(targets) == 0 {
+						content = "Nothing to retry, use `retry all` or `retry <id>[,<id>...]`"
+						break
+					}
+					safeReply(ctx, fmt.Sprintf("Retrying %d failed download%s...", len(targets), pluralS(len(targets))))
+					log.Println(lg("Command", "Failures", color.HiCyanString, "%s requested retry of %d failed download%s",
+						getUserIdentifier(*ctx.Msg.Author), len(targets), pluralS(len(targets))))
+					succeeded := retryDownloadFailures(targets)
+					content = fmt.Sprintf("Recovered %d of %d failed download%s, %d still queued",
+						succeeded, len(targets), pluralS(len(targets)), len(dbGetFailures()))
+				case "purge", "clear":
+					if len(targets) == 0 {
+						content = "Nothing to purge, use `purge all` or `purge <id>[,<id>...]`"
+						break
+					}
+					purged := 0
+					for _, failure := range targets {
+						if err := dbDeleteFailure(failure.ID); err == nil {
+							purged++
+						}
+					}
+					log.Println(lg("Command", "Failures", color.HiCyanString, "%s purged %d failed download%s",
+						getUserIdentifier(*ctx.Msg.Author), purged, pluralS(purged)))
+					content = fmt.Sprintf("Purged %d failed download%s", purged, pluralS(purged))
+				default: // list
+					if len(failures) == 0 {
+						content = "No failed downloads queued"
+						break
+					}
+					content = formatDownloadFailureList(failures)
+				}
+				if _, err := replyEmbed(ctx.Msg, "Command — Failures", content); err != nil {
+					log.Println(lg("Command", "Failures", color.HiRedString,
+						cmderrSendFailure, getUserIdentifier(*ctx.Msg.Author), err))
+				}
+			}
+		}
+	}).Cat("Admin").Alias("failed").Desc("Lists, retries (retry all|<id>) or purges (purge all|<id>) failed downloads")
+
+	go router.On("extractors", func(ctx *exrouter.Context) {
+		if isCommandableChannel(ctx.Msg) {
+			if !hasPerms(ctx.Msg.ChannelID, discordgo.PermissionSendMessages) {
+				log.Println(lg("Command", "Extractors", color.HiRedString, fmtBotSendPerm, ctx.Msg.ChannelID))
+			} else if !isBotAdmin(ctx.Msg) {
+				if _, err := replyEmbed(ctx.Msg, "Command — Extractors", cmderrLackingBotAdminPerms); err != nil {
+					log.Println(lg("Command", "Extractors", color.HiRedString,
+						cmderrSendFailure, getUserIdentifier(*ctx.Msg.Author), err))
+				}
+				log.Println(lg("Command", "Extractors", color.HiCyanString,
+					"%s tried to view extractor stats but lacked bot admin perms.", getUserIdentifier(*ctx.Msg.Author)))
+			} else {
+				if _, err := replyEmbed(ctx.Msg, "Command — Extractors", formatExtractorStats()); err != nil {
+					log.Println(lg("Command", "Extractors", color.HiRedString,
+						cmderrSendFailure, getUserIdentifier(*ctx.Msg.Author), err))
+				}
+				log.Println(lg("Command", "Extractors", color.HiCyanString, "%s requested extractor stats",
+					getUserIdentifier(*ctx.Msg.Author)))
+			}
+		}
+	}).Cat("Admin").Desc("Lists link extractors with their match & error counts since startup")
+
 	go router.On("exit", func(ctx *exrouter.Context) {
 		if isCommandableChannel(ctx.Msg) {
 			if isBotAdmin(ctx.Msg) {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Identifies files by their leading bytes rather than trusting the URL or server, since plenty of
+// hosts serve everything as application/octet-stream and plenty of links have no extension at all.
+
+type fileType struct {
+	MIME string
+	// Extensions this type is commonly saved as, the first being the one to use when fixing.
+	Extensions []string
+}
+
+func (ft fileType) base() string {
+	return strings.Split(ft.MIME, "/")[0]
+}
+
+func (ft fileType) extension() string {
+	if len(ft.Extensions) > 0 {
+		return ft.Extensions[0]
+	}
+	return ""
+}
+
+func (ft fileType) hasExtension(extension string) bool {
+	return stringInSlice(strings.ToLower(extension), ft.Extensions)
+}
+
+type fileSignature struct {
+	Offset int
+	Magic  []byte
+	Type   fileType
+}
+
+// Checked in order, anything needing more than a fixed prefix is handled in detectFileType.
+var fileSignatures = []fileSignature{
+	// Images
+	{0, []byte{0xFF, 0xD8, 0xFF}, fileType{"image/jpeg", []string{".jpg", ".jpeg", ".jfif", ".jpe"}}},
+	{0, []byte("\x89PNG\r\n\x1a\n"), fileType{"image/png", []string{".png", ".apng"}}},
+	{0, []byte("GIF87a"), fileType{"image/gif", []string{".gif"}}},
+	{0, []byte("GIF89a"), fileType{"image/gif", []string{".gif"}}},
+	{0, []byte("II*\x00"), fileType{"image/tiff", []string{".tif", ".tiff", ".nef", ".dng", ".cr2", ".arw"}}},
+	{0, []byte("MM\x00*"), fileType{"image/tiff", []string{".tif", ".tiff", ".nef", ".dng", ".cr2", ".arw"}}},
+	{0, []byte("8BPS"), fileType{"image/vnd.adobe.photoshop", []string{".psd", ".psb"}}},
+	{0, []byte{0xFF, 0x0A}, fileType{"image/jxl", []string{".jxl"}}},
+	{0, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n"), fileType{"image/jxl", []string{".jxl"}}},
+	{0, []byte{0x00, 0x00, 0x01, 0x00}, fileType{"image/x-icon", []string{".ico"}}},
+	{0, []byte("BM"), fileType{"image/bmp", []string{".bmp"}}},
+
+	// Video
+	{0, []byte("FLV\x01"), fileType{"video/x-flv", []string{".flv"}}},
+	{0, []byte{0x00, 0x00, 0x01, 0xBA}, fileType{"video/mpeg", []string{".mpg", ".mpeg", ".vob"}}},
+	{0, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}, fileType{"video/x-ms-asf", []string{".wmv", ".asf", ".wma"}}},
+
+	// Audio
+	{0, []byte("fLaC"), fileType{"audio/flac", []string{".flac"}}},
+	{0, []byte("ID3"), fileType{"audio/mpeg", []string{".mp3"}}},
+	{0, []byte("MThd"), fileType{"audio/midi", []string{".mid", ".midi"}}},
+	{0, []byte("#!AMR"), fileType{"audio/amr", []string{".amr"}}},
+
+	// Archives & documents
+	{0, []byte("PK\x03\x04"), fileType{"application/zip", []string{".zip", ".cbz", ".docx", ".xlsx", ".pptx", ".odt", ".epub", ".jar", ".apk"}}},
+	{0, []byte("PK\x05\x06"), fileType{"application/zip", []string{".zip"}}},
+	{0, []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, fileType{"application/x-7z-compressed", []string{".7z"}}},
+	{0, []byte("Rar!\x1a\x07"), fileType{"application/vnd.rar", []string{".rar", ".cbr"}}},
+	{0, []byte{0x1F, 0x8B}, fileType{"application/gzip", []string{".gz", ".tgz"}}},
+	{0, []byte("BZh"), fileType{"application/x-bzip2", []string{".bz2", ".tbz2"}}},
+	{0, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, fileType{"application/x-xz", []string{".xz", ".txz"}}},
+	{0, []byte{0x28, 0xB5, 0x2F, 0xFD}, fileType{"application/zstd", []string{".zst"}}},
+	{257, []byte("ustar"), fileType{"application/x-tar", []string{".tar"}}},
+	{0, []byte("%PDF-"), fileType{"application/pdf", []string{".pdf"}}},
+}
+
+// ISO base media files (MP4, MOV, HEIF, AVIF...) all start with an ftyp box, the brand says which.
+var fileTypeBrands = map[string]fileType{
+	"avif": {"image/avif", []string{".avif"}},
+	"avis": {"image/avif", []string{".avif"}},
+	"heic": {"image/heic", []string{".heic", ".heif"}},
+	"heix": {"image/heic", []string{".heic", ".heif"}},
+	"heim": {"image/heic", []string{".heic", ".heif"}},
+	"heis": {"image/heic", []string{".heic", ".heif"}},
+	"hevc": {"image/heic-sequence", []string{".heic", ".heif"}},
+	"mif1": {"image/heif", []string{".heif", ".heic"}},
+	"msf1": {"image/heif-sequence", []string{".heif", ".heic"}},
+	"crx ": {"image/x-canon-cr3", []string{".cr3"}},
+	"qt  ": {"video/quicktime", []string{".mov", ".qt"}},
+	"M4A ": {"audio/mp4", []string{".m4a"}},
+	"M4B ": {"audio/mp4", []string{".m4b", ".m4a"}},
+	"M4V ": {"video/mp4", []string{".m4v", ".mp4"}},
+	"3gp4": {"video/3gpp", []string{".3gp"}},
+	"3gp5": {"video/3gpp", []string{".3gp"}},
+	"3gp6": {"video/3gpp", []string{".3gp"}},
+	"3g2a": {"video/3gpp2", []string{".3g2"}},
+}
+
+var fileTypeMP4 = fileType{"video/mp4", []string{".mp4", ".m4v", ".mov"}}
+
+// detectFileType identifies the content from the first bytes of a file (512 is plenty),
+// falling back on net/http's sniffing for anything not in the table.
+func detectFileType(head []byte) fileType {
+	at := func(offset int, magic string) bool {
+		return len(head) >= offset+len(magic) && string(head[offset:offset+len(magic)]) == magic
+	}
+
+	switch {
+	case at(4, "ftyp") && len(head) >= 12:
+		if ft, ok := fileTypeBrands[string(head[8:12])]; ok {
+			return ft
+		}
+		return fileTypeMP4
+
+	case at(0, "RIFF"):
+		switch {
+		case at(8, "WEBP"):
+			return fileType{"image/webp", []string{".webp"}}
+		case at(8, "AVI "):
+			return fileType{"video/x-msvideo", []string{".avi"}}
+		case at(8, "WAVE"):
+			return fileType{"audio/wav", []string{".wav"}}
+		}
+
+	case at(0, "FORM") && (at(8, "AIFF") || at(8, "AIFC")):
+		return fileType{"audio/aiff", []string{".aiff", ".aif"}}
+
+	case at(0, "\x1a\x45\xdf\xa3"): // EBML, the DocType is near the start
+		if bytes.Contains(head, []byte("webm")) {
+			return fileType{"video/webm", []string{".webm"}}
+		}
+		return fileType{"video/x-matroska", []string{".mkv", ".mka", ".mk3d"}}
+
+	case at(0, "OggS"):
+		switch {
+		case bytes.Contains(head, []byte("OpusHead")):
+			return fileType{"audio/ogg", []string{".opus", ".ogg"}}
+		case bytes.Contains(head, []byte("\x80theora")):
+			return fileType{"video/ogg", []string{".ogv"}}
+		}
+		return fileType{"audio/ogg", []string{".ogg", ".oga"}}
+
+	case len(head) > 188 && head[0] == 0x47 && head[188] == 0x47: // MPEG transport stream packets
+		return fileType{"video/mp2t", []string{".ts", ".m2ts"}}
+	}
+
+	for _, signature := range fileSignatures {
+		if at(signature.Offset, string(signature.Magic)) {
+			return signature.Type
+		}
+	}
+
+	// MPEG audio frames without a tag, ADTS AAC shares the sync bits
+	if len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 {
+		switch head[1] & 0x06 {
+		case 0x00:
+			if head[1]&0xF0 == 0xF0 {
+				return fileType{"audio/aac", []string{".aac"}}
+			}
+		case 0x02:
+			return fileType{"audio/mpeg", []string{".mp3"}}
+		}
+	}
+
+	contentType := http.DetectContentType(head)
+	ft := fileType{MIME: contentType}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		ft.MIME = mediaType
+	}
+	if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+		ft.Extensions = extensions
+	}
+	// The system's list is sorted alphabetically, which puts odd ones first for some types
+	if preferred, ok := fileTypePreferredExtensions[ft.MIME]; ok {
+		ft.Extensions = append([]string{preferred}, ft.Extensions...)
+	}
+	return ft
+}
+
+var fileTypePreferredExtensions = map[string]string{
+	"text/plain":       ".txt",
+	"text/html":        ".html",
+	"text/xml":         ".xml",
+	"application/json": ".json",
+	"audio/wave":       ".wav",
+}
+
+// Only for when the content itself told us nothing
+func fileTypeFromExtension(extension string) string {
+	switch strings.ToLower(extension) {
+	case ".mov", ".mp4", ".m4v", ".webm", ".mkv":
+		return "video"
+	case ".psd", ".nef", ".dng", ".tif", ".tiff", ".heic", ".avif":
+		return "image"
+	}
+	return ""
+}
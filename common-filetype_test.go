@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectFileType(t *testing.T) {
+	pad := func(head string) []byte {
+		return append([]byte(head), make([]byte, 64)...)
+	}
+	tar := make([]byte, 512)
+	copy(tar, "notes.txt")
+	copy(tar[257:], "ustar\x0000")
+	ts := make([]byte, 400)
+	ts[0], ts[188], ts[376] = 0x47, 0x47, 0x47
+
+	tests := []struct {
+		name      string
+		head      []byte
+		mime      string
+		extension string
+	}{
+		{"jpeg", pad("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg", ".jpg"},
+		{"png", pad("\x89PNG\r\n\x1a\n"), "image/png", ".png"},
+		{"gif", pad("GIF89a"), "image/gif", ".gif"},
+		{"webp", pad("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp", ".webp"},
+		{"avi", pad("RIFF\x00\x00\x00\x00AVI LIST"), "video/x-msvideo", ".avi"},
+		{"wav", pad("RIFF\x00\x00\x00\x00WAVEfmt "), "audio/wav", ".wav"},
+		{"mp4", pad("\x00\x00\x00\x20ftypisom"), "video/mp4", ".mp4"},
+		{"mov", pad("\x00\x00\x00\x14ftypqt  "), "video/quicktime", ".mov"},
+		{"heic", pad("\x00\x00\x00\x18ftypheic"), "image/heic", ".heic"},
+		{"avif", pad("\x00\x00\x00\x1cftypavif"), "image/avif", ".avif"},
+		{"webm", pad("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "video/webm", ".webm"},
+		{"mkv", pad("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), "video/x-matroska", ".mkv"},
+		{"opus", pad("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00OpusHead"), "audio/ogg", ".opus"},
+		{"mp3 tagged", pad("ID3\x04\x00"), "audio/mpeg", ".mp3"},
+		{"mp3 frame", pad("\xff\xfb\x90\x64"), "audio/mpeg", ".mp3"},
+		{"aac", pad("\xff\xf1\x50\x80"), "audio/aac", ".aac"},
+		{"flac", pad("fLaC"), "audio/flac", ".flac"},
+		{"zip", pad("PK\x03\x04"), "application/zip", ".zip"},
+		{"7z", pad("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed", ".7z"},
+		{"rar", pad("Rar!\x1a\x07\x01\x00"), "application/vnd.rar", ".rar"},
+		{"tar", tar, "application/x-tar", ".tar"},
+		{"pdf", pad("%PDF-1.7"), "application/pdf", ".pdf"},
+		{"transport stream", ts, "video/mp2t", ".ts"},
+		{"html", []byte("<!DOCTYPE html><html><body>hi</body></html>"), "text/html", ".html"},
+		{"text", []byte("just some words"), "text/plain", ".txt"},
+	}
+	for _, test := range tests {
+		ft := detectFileType(test.head)
+		if ft.MIME != test.mime || ft.extension() != test.extension {
+			t.Errorf("%s: detected %s %q, want %s %q", test.name, ft.MIME, ft.extension(), test.mime, test.extension)
+		}
+	}
+}
+
+func TestFileTypeHasExtension(t *testing.T) {
+	jpeg := detectFileType([]byte("\xff\xd8\xff\xe0"))
+	if !jpeg.hasExtension(".JPEG") || !jpeg.hasExtension(".jfif") || jpeg.hasExtension(".png") {
+		t.Errorf("extensions = %v", jpeg.Extensions)
+	}
+	if jpeg.base() != "image" {
+		t.Errorf("base = %s", jpeg.base())
+	}
+	// Short reads still go by what's there
+	if ft := detectFileType(bytes.Repeat([]byte{0}, 3)); ft.MIME == "" {
+		t.Error("nothing detected for a short head")
+	}
+}
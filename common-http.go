@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fatih/color"
+)
+
+//#region Domain Limits
+
+// Longest a Retry-After header is allowed to hold back a domain.
+const retryAfterMax = 15 * time.Minute
+
+type domainLimiter struct {
+	key          string
+	mutex        sync.Mutex
+	slotFreed    chan struct{} // closed & replaced whenever a connection slot frees up
+	tokens       float64
+	lastRefill   time.Time
+	lastRequest  time.Time
+	blockedUntil time.Time
+	connections  int
+}
+
+var (
+	domainLimitersMutex sync.Mutex
+	domainLimiters      = map[string]*domainLimiter{}
+)
+
+// hostInDomains is true when host is one of domains or a subdomain of one.
+func hostInDomains(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDomainLimit finds the most specific configured limit for host, along with the domain it matched.
+func getDomainLimit(host string) (configurationDomainLimit, string) {
+	host = strings.ToLower(host)
+	var match configurationDomainLimit
+	matched := ""
+	for _, limit := range config.DomainLimits {
+		for _, domain := range limit.Domains {
+			domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+			if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(matched) {
+				match = limit
+				matched = domain
+			}
+		}
+	}
+	return match, matched
+}
+
+func getDomainLimiter(host string) *domainLimiter {
+	_, key := getDomainLimit(host)
+	if key == "" { // still tracked so Retry-After applies to unconfigured hosts
+		key = strings.ToLower(host)
+	}
+	domainLimitersMutex.Lock()
+	defer domainLimitersMutex.Unlock()
+	limiter, exists := domainLimiters[key]
+	if !exists {
+		limiter = &domainLimiter{key: key, tokens: -1, slotFreed: make(chan struct{})}
+		domainLimiters[key] = limiter
+	}
+	return limiter
+}
+
+// wait blocks until the domain allows another request or ctx is done, the returned func frees the connection slot.
+// Limits are looked up every time so changes from a settings reload apply right away.
+func (limiter *domainLimiter) wait(ctx context.Context) (func(), error) {
+	limiter.mutex.Lock()
+	for {
+		limit, _ := getDomainLimit(limiter.key)
+		now := time.Now()
+
+		if limit.MaxConnections > 0 && limiter.connections >= limit.MaxConnections {
+			slotFreed := limiter.slotFreed
+			limiter.mutex.Unlock()
+			select {
+			case <-slotFreed:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			limiter.mutex.Lock()
+			continue
+		}
+
+		// Refill bucket
+		burst := math.Max(1, float64(limit.Burst))
+		if limiter.tokens < 0 {
+			limiter.tokens = burst
+		} else if limit.RequestsPerSecond > 0 {
+			limiter.tokens = math.Min(burst,
+				limiter.tokens+now.Sub(limiter.lastRefill).Seconds()*limit.RequestsPerSecond)
+		}
+		limiter.lastRefill = now
+
+		var delay time.Duration
+		if now.Before(limiter.blockedUntil) {
+			delay = limiter.blockedUntil.Sub(now)
+		}
+		if minDelay := time.Duration(limit.MinDelay) * time.Millisecond; now.Sub(limiter.lastRequest) < minDelay {
+			delay = time.Duration(math.Max(float64(delay), float64(minDelay-now.Sub(limiter.lastRequest))))
+		}
+		if limit.RequestsPerSecond > 0 && limiter.tokens < 1 {
+			tokenDelay := time.Duration((1 - limiter.tokens) / limit.RequestsPerSecond * float64(time.Second))
+			delay = time.Duration(math.Max(float64(delay), float64(tokenDelay)))
+		}
+
+		if delay <= 0 {
+			if limit.RequestsPerSecond > 0 {
+				limiter.tokens--
+			}
+			limiter.lastRequest = now
+			limiter.connections++
+			limiter.mutex.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					limiter.mutex.Lock()
+					limiter.connections--
+					close(limiter.slotFreed)
+					limiter.slotFreed = make(chan struct{})
+					limiter.mutex.Unlock()
+				})
+			}, nil
+		}
+
+		limiter.mutex.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		limiter.mutex.Lock()
+	}
+}
+
+// holdOff keeps the domain from being requested again before the time given by Retry-After.
+func (limiter *domainLimiter) holdOff(response *http.Response) {
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+	retryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+	if retryAfter <= 0 {
+		return
+	}
+	if retryAfter > retryAfterMax {
+		retryAfter = retryAfterMax
+	}
+	limiter.mutex.Lock()
+	if until := time.Now().Add(retryAfter); until.After(limiter.blockedUntil) {
+		limiter.blockedUntil = until
+	}
+	limiter.mutex.Unlock()
+	log.Println(lg("Download", "", color.YellowString,
+		"%s asked us to back off, holding requests for %s", limiter.key, retryAfter))
+}
+
+// Retry-After is either a number of seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+//#endregion
+
+//#region Request Profiles
+
+// Sent unless a profile says otherwise.
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+type cookieJarCache struct {
+	jar     http.CookieJar
+	modTime time.Time
+}
+
+var (
+	requestProfileMutex   sync.Mutex
+	requestProfileRegexes = map[string]*regexp.Regexp{}
+	requestProfileJars    = map[string]*cookieJarCache{}
+)
+
+func (profile configurationRequestProfile) matches(link *url.URL) bool {
+	if hostInDomains(link.Hostname(), profile.Domains) {
+		return true
+	}
+	if profile.URLRegex != "" {
+		requestProfileMutex.Lock()
+		regex, exists := requestProfileRegexes[profile.URLRegex]
+		if !exists {
+			var err error
+			if regex, err = regexp.Compile(profile.URLRegex); err != nil {
+				log.Println(lg("Settings", "Request Profiles", color.HiRedString,
+					"Invalid urlRegex for profile \"%s\": %s", profile.Name, err))
+			}
+			requestProfileRegexes[profile.URLRegex] = regex // nil for invalid, only complain once
+		}
+		requestProfileMutex.Unlock()
+		if regex != nil && regex.MatchString(link.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRequestProfiles sets the default user agent, then whatever the matching profiles add on top.
+func applyRequestProfiles(request *http.Request) {
+	if request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", defaultUserAgent)
+	}
+	for _, profile := range config.RequestProfiles {
+		if !profile.matches(request.URL) {
+			continue
+		}
+		if profile.UserAgent != "" {
+			request.Header.Set("User-Agent", profile.UserAgent)
+		}
+		if profile.Referer != "" {
+			request.Header.Set("Referer", profile.Referer)
+		}
+		for key, value := range profile.Headers {
+			request.Header.Set(key, value)
+		}
+		if profile.CookiesFile != "" {
+			if jar := getCookieJar(profile.CookiesFile); jar != nil {
+				for _, cookie := range jar.Cookies(request.URL) {
+					request.AddCookie(cookie)
+				}
+			}
+		}
+	}
+}
+
+// getCookieJar loads a cookies.txt, reloading it whenever the file changes.
+func getCookieJar(path string) http.CookieJar {
+	stat, err := os.Stat(path)
+	if err != nil {
+		log.Println(lg("Settings", "Request Profiles", color.HiRedString, "Can't read cookies file \"%s\": %s", path, err))
+		return nil
+	}
+	requestProfileMutex.Lock()
+	defer requestProfileMutex.Unlock()
+	if cached, exists := requestProfileJars[path]; exists && cached.modTime.Equal(stat.ModTime()) {
+		return cached.jar
+	}
+	jar, err := loadCookiesFile(path)
+	if err != nil {
+		log.Println(lg("Settings", "Request Profiles", color.HiRedString, "Can't load cookies file \"%s\": %s", path, err))
+		return nil
+	}
+	requestProfileJars[path] = &cookieJarCache{jar, stat.ModTime()}
+	return jar
+}
+
+// Netscape format, as exported by browser extensions & curl. Tab separated:
+// domain, include subdomains, path, secure, expiry (unix), name, value
+func loadCookiesFile(path string) (http.CookieJar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+		domain := strings.TrimPrefix(fields[0], ".")
+		scheme := "http"
+		if strings.EqualFold(fields[3], "TRUE") {
+			scheme = "https"
+		}
+		cookie := &http.Cookie{
+			Name:   fields[5],
+			Value:  fields[6],
+			Path:   fields[2],
+			Secure: scheme == "https",
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: fields[2]}, []*http.Cookie{cookie})
+	}
+	return jar, scanner.Err()
+}
+
+//#endregion
+
+//#region Transport
+
+// Shared by everything going through doRequest, so connections (and HTTP/2 sessions) get reused.
+// Starts out with Go's defaults for anything requested before the settings are loaded.
+var httpClient = &http.Client{}
+
+// For requests that don't set a timeout or deadline of their own.
+const defaultRequestTimeout = 2 * time.Minute
+
+type proxyContextKey struct{}
+type timeoutContextKey struct{}
+
+// withProxy routes a request through proxy regardless of request profiles.
+func withProxy(ctx context.Context, proxy string) context.Context {
+	return context.WithValue(ctx, proxyContextKey{}, proxy)
+}
+
+// getSourceProxy is the proxy set for m's source, empty for none.
+func getSourceProxy(m *discordgo.Message) string {
+	if m == nil {
+		return ""
+	}
+	if sourceConfig := getSource(m); sourceConfig.Proxy != nil {
+		return *sourceConfig.Proxy
+	}
+	return ""
+}
+
+// requestContext is for requests made on behalf of m, routed through its source's proxy when it has one.
+func requestContext(m *discordgo.Message) context.Context {
+	if proxy := getSourceProxy(m); proxy != "" {
+		return withProxy(context.Background(), proxy)
+	}
+	return context.Background()
+}
+
+// withRequestTimeout limits how long a request can take, counted from when the domain's limits let it
+// through rather than from when it was queued.
+func withRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutContextKey{}, timeout)
+}
+
+// Picks the proxy for each request (redirects included): one set on the request's context,
+// then the last matching request profile with one, then the usual environment variables.
+func proxyForRequest(request *http.Request) (*url.URL, error) {
+	proxy, _ := request.Context().Value(proxyContextKey{}).(string)
+	if proxy == "" {
+		for _, profile := range config.RequestProfiles {
+			if profile.Proxy != "" && profile.matches(request.URL) {
+				proxy = profile.Proxy
+			}
+		}
+	}
+	if proxy == "" {
+		return http.ProxyFromEnvironment(request)
+	}
+	if strings.EqualFold(proxy, "direct") {
+		return nil, nil
+	}
+	return url.Parse(proxy)
+}
+
+func setupHTTPClient() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyForRequest
+	transport.MaxIdleConnsPerHost = 8 // Go's 2 has the workers reconnecting constantly
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if settings := config.HTTPTransport; settings != nil {
+		if settings.MaxIdleConns != nil {
+			transport.MaxIdleConns = *settings.MaxIdleConns
+		}
+		if settings.MaxIdleConnsPerHost != nil {
+			transport.MaxIdleConnsPerHost = *settings.MaxIdleConnsPerHost
+		}
+		if settings.MaxConnsPerHost != nil {
+			transport.MaxConnsPerHost = *settings.MaxConnsPerHost
+		}
+		if settings.IdleConnTimeout != nil {
+			transport.IdleConnTimeout = time.Duration(*settings.IdleConnTimeout) * time.Second
+		}
+		if settings.KeepAlive != nil {
+			dialer.KeepAlive = time.Duration(*settings.KeepAlive) * time.Second
+		}
+		if settings.DialTimeout != nil {
+			dialer.Timeout = time.Duration(*settings.DialTimeout) * time.Second
+		}
+		if settings.TLSHandshakeTimeout != nil {
+			transport.TLSHandshakeTimeout = time.Duration(*settings.TLSHandshakeTimeout) * time.Second
+		}
+		if settings.TLSInsecureSkipVerify != nil && *settings.TLSInsecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			log.Println(lg("Settings", "HTTP", color.HiYellowString, "TLS certificate verification is disabled"))
+		}
+		if settings.DisableHTTP2 != nil && *settings.DisableHTTP2 {
+			transport.ForceAttemptHTTP2 = false
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+	}
+	transport.DialContext = dialer.DialContext
+
+	httpClient = &http.Client{Transport: transport}
+}
+
+//#endregion
+
+//#region Requests
+
+// Frees the domain's connection slot (and the request's timeout) once the caller is done with the body.
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (body *limitedBody) Close() error {
+	defer body.release()
+	return body.ReadCloser.Close()
+}
+
+// doRequest sends request through the shared client after applying request profiles & waiting on the domain's limits.
+// Every outgoing request for downloads & link parsing should go through here. The timeout set with withRequestTimeout
+// starts once the request is let through, requests with neither that nor a deadline get defaultRequestTimeout.
+func doRequest(request *http.Request) (*http.Response, error) {
+	applyRequestProfiles(request)
+	limiter := getDomainLimiter(request.URL.Hostname())
+	releaseSlot, err := limiter.wait(request.Context())
+	if err != nil {
+		return nil, err
+	}
+	timeout, hasTimeout := request.Context().Value(timeoutContextKey{}).(time.Duration)
+	if _, hasDeadline := request.Context().Deadline(); !hasTimeout && !hasDeadline {
+		timeout, hasTimeout = defaultRequestTimeout, true
+	}
+	release := releaseSlot
+	if hasTimeout {
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		request = request.WithContext(ctx)
+		release = func() {
+			cancel()
+			releaseSlot()
+		}
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	limiter.holdOff(response)
+	response.Body = &limitedBody{response.Body, release}
+	return response, nil
+}
+
+//#endregion
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDomainLimiterWaitRespectsContext(t *testing.T) {
+	config = defaultConfiguration()
+	config.DomainLimits = []configurationDomainLimit{{Domains: []string{"limited.test"}, MaxConnections: 1}}
+	limiter := getDomainLimiter("limited.test")
+
+	release, err := limiter.wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("waiting on a taken slot returned %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	releaseNext, err := limiter.wait(ctx)
+	if err != nil {
+		t.Fatalf("slot wasn't handed over once freed: %v", err)
+	}
+	releaseNext()
+}
+
+func TestDomainLimiterBlockedRespectsContext(t *testing.T) {
+	config = defaultConfiguration()
+	limiter := getDomainLimiter("blocked.test")
+	limiter.holdOff(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := limiter.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("waiting out Retry-After returned %v", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %s past the context", waited)
+	}
+}
+
+// The timeout counts from when a slot is free, not from when the request was queued.
+func TestDoRequestTimeoutStartsAfterLimits(t *testing.T) {
+	config = defaultConfiguration()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	config.DomainLimits = []configurationDomainLimit{{Domains: []string{request.URL.Hostname()}, MaxConnections: 1}}
+
+	release, err := getDomainLimiter(request.URL.Hostname()).wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		release()
+	}()
+
+	request = request.WithContext(withRequestTimeout(context.Background(), 200*time.Millisecond))
+	response, err := doRequest(request)
+	if err != nil {
+		t.Fatalf("request timed out while queued: %v", err)
+	}
+	response.Body.Close()
+
+	request = request.WithContext(withRequestTimeout(context.Background(), 10*time.Millisecond))
+	if response, err := doRequest(request); err == nil {
+		response.Body.Close()
+		t.Error("slow response didn't time out")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("seconds = %s", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("date = %s", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("invalid = %s", got)
+	}
+}
+
+func TestRequestContextUsesSourceProxy(t *testing.T) {
+	source := setupTestDownloads(t)
+	proxy := "http://proxy.test:8080"
+	source.Proxy = &proxy
+	useTestSource(source)
+
+	m := &discordgo.Message{ChannelID: testChannelID}
+	request, _ := http.NewRequestWithContext(requestContext(m), "GET", "https://example.com/post", nil)
+	if got, err := proxyForRequest(request); err != nil || got == nil || got.String() != proxy {
+		t.Errorf("proxy = %v, %v", got, err)
+	}
+
+	bot.State.ChannelAdd(&discordgo.Channel{ID: "999", Type: discordgo.ChannelTypeDM})
+	request, _ = http.NewRequestWithContext(requestContext(&discordgo.Message{ChannelID: "999"}), "GET", "https://example.com/post", nil)
+	if got, _ := proxyForRequest(request); got != nil && got.String() == proxy {
+		t.Error("proxy used for a message from elsewhere")
+	}
+}
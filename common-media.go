@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+)
+
+var errUnknownDimensions = errors.New("could not determine dimensions")
+
+// getMediaDimensions reads the pixel size of an image or video from its headers, without decoding any frames.
+func getMediaDimensions(path string) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	if cfg, _, err := image.DecodeConfig(file); err == nil {
+		return cfg.Width, cfg.Height, nil
+	}
+
+	head := make([]byte, 32)
+	if _, err := file.ReadAt(head, 0); err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	switch {
+	case bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return getWebpDimensions(head)
+	case bytes.Equal(head[4:8], []byte("ftyp")):
+		return getMp4Dimensions(file)
+	case bytes.Equal(head[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return getEbmlDimensions(file)
+	}
+	return 0, 0, errUnknownDimensions
+}
+
+func getWebpDimensions(head []byte) (int, int, error) {
+	switch string(head[12:16]) {
+	case "VP8X": // extended, canvas size is stored minus one in 24 bits each
+		width := int(head[24]) | int(head[25])<<8 | int(head[26])<<16
+		height := int(head[27]) | int(head[28])<<8 | int(head[29])<<16
+		return width + 1, height + 1, nil
+	case "VP8 ": // lossy, after the frame tag & start code
+		if head[23] == 0x9D && head[24] == 0x01 && head[25] == 0x2A {
+			return int(binary.LittleEndian.Uint16(head[26:28]) & 0x3FFF),
+				int(binary.LittleEndian.Uint16(head[28:30]) & 0x3FFF), nil
+		}
+	case "VP8L": // lossless, 14 bits each after the signature byte
+		if head[20] == 0x2F {
+			bits := binary.LittleEndian.Uint32(head[21:25])
+			return int(bits&0x3FFF) + 1, int((bits>>14)&0x3FFF) + 1, nil
+		}
+	}
+	return 0, 0, errUnknownDimensions
+}
+
+// MP4/MOV keep the presentation size of each track in moov > trak > tkhd
+func getMp4Dimensions(file *os.File) (int, int, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	var width, height int
+	var walk func(start, end int64) bool
+	walk = func(start, end int64) bool {
+		header := make([]byte, 16)
+		for offset := start; offset+8 <= end; {
+			if _, err := file.ReadAt(header[:8], offset); err != nil {
+				return false
+			}
+			size := int64(binary.BigEndian.Uint32(header[0:4]))
+			boxType := string(header[4:8])
+			headerSize := int64(8)
+			if size == 1 {
+				if _, err := file.ReadAt(header[8:16], offset+8); err != nil {
+					return false
+				}
+				size = int64(binary.BigEndian.Uint64(header[8:16]))
+				headerSize = 16
+			} else if size == 0 {
+				size = end - offset
+			}
+			if size < headerSize || offset+size > end {
+				return false
+			}
+
+			switch boxType {
+			case "moov", "trak":
+				if walk(offset+headerSize, offset+size) {
+					return true
+				}
+			case "tkhd":
+				version := make([]byte, 1)
+				if _, err := file.ReadAt(version, offset+headerSize); err != nil {
+					return false
+				}
+				sizeOffset := int64(76)
+				if version[0] == 1 {
+					sizeOffset = 88
+				}
+				dims := make([]byte, 8)
+				if _, err := file.ReadAt(dims, offset+headerSize+sizeOffset); err != nil {
+					return false
+				}
+				// 16.16 fixed point, audio tracks are 0x0
+				if w, h := int(binary.BigEndian.Uint32(dims[0:4])>>16), int(binary.BigEndian.Uint32(dims[4:8])>>16); w > 0 && h > 0 {
+					width, height = w, h
+					return true
+				}
+			}
+			offset += size
+		}
+		return false
+	}
+	if walk(0, stat.Size()) {
+		return width, height, nil
+	}
+	return 0, 0, errUnknownDimensions
+}
+
+// Matroska/WebM keep them in Segment > Tracks > TrackEntry > Video > PixelWidth/PixelHeight
+func getEbmlDimensions(file *os.File) (int, int, error) {
+	const (
+		idSegment     = 0x18538067
+		idTracks      = 0x1654AE6B
+		idTrackEntry  = 0xAE
+		idVideo       = 0xE0
+		idPixelWidth  = 0xB0
+		idPixelHeight = 0xBA
+		idCluster     = 0x1F43B675
+	)
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Variable length ints, IDs keep their length marker while sizes drop it
+	readVint := func(offset int64, keepMarker bool) (uint64, int64, bool) {
+		first := make([]byte, 1)
+		if _, err := file.ReadAt(first, offset); err != nil || first[0] == 0 {
+			return 0, 0, false
+		}
+		length := int64(1)
+		for mask := byte(0x80); first[0]&mask == 0; mask >>= 1 {
+			length++
+		}
+		data := make([]byte, length)
+		if _, err := file.ReadAt(data, offset); err != nil {
+			return 0, 0, false
+		}
+		if !keepMarker {
+			data[0] &= 0xFF >> length
+		}
+		var value uint64
+		for _, b := range data {
+			value = value<<8 | uint64(b)
+		}
+		return value, length, true
+	}
+
+	var width, height int
+	var walk func(start, end int64)
+	walk = func(start, end int64) {
+		for offset := start; offset < end && (width == 0 || height == 0); {
+			id, idLength, ok := readVint(offset, true)
+			if !ok {
+				return
+			}
+			size, sizeLength, ok := readVint(offset+idLength, false)
+			if !ok {
+				return
+			}
+			dataStart := offset + idLength + sizeLength
+			dataEnd := dataStart + int64(size)
+			if size == (1<<(7*sizeLength))-1 || dataEnd > end { // unknown size, runs to the parent's end
+				dataEnd = end
+			}
+
+			switch id {
+			case idSegment, idTracks, idTrackEntry, idVideo:
+				walk(dataStart, dataEnd)
+			case idPixelWidth, idPixelHeight:
+				if dataEnd-dataStart > 8 {
+					return
+				}
+				data := make([]byte, dataEnd-dataStart)
+				if _, err := file.ReadAt(data, dataStart); err != nil {
+					return
+				}
+				var value int
+				for _, b := range data {
+					value = value<<8 | int(b)
+				}
+				if id == idPixelWidth {
+					width = value
+				} else {
+					height = value
+				}
+			case idCluster: // media data, tracks always come before it
+				return
+			}
+			offset = dataEnd
+		}
+	}
+	walk(0, stat.Size())
+	if width > 0 && height > 0 {
+		return width, height, nil
+	}
+	return 0, 0, errUnknownDimensions
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestMedia(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mp4Box(boxType string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	box := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(box, uint32(8+len(content)))
+	copy(box[4:], boxType)
+	return append(box, content...)
+}
+
+// Track header with the size as 16.16 fixed point where the version puts it
+func mp4Tkhd(version byte, width, height int) []byte {
+	sizeOffset := 76
+	if version == 1 {
+		sizeOffset = 88
+	}
+	payload := make([]byte, sizeOffset+8)
+	payload[0] = version
+	binary.BigEndian.PutUint32(payload[sizeOffset:], uint32(width<<16))
+	binary.BigEndian.PutUint32(payload[sizeOffset+4:], uint32(height<<16))
+	return mp4Box("tkhd", payload)
+}
+
+func ebmlElement(id []byte, data ...[]byte) []byte {
+	content := bytes.Join(data, nil)
+	return append(append(append([]byte{}, id...), 0x80|byte(len(content))), content...)
+}
+
+func TestGetMediaDimensions(t *testing.T) {
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 40, 30)))
+
+	webpHead := func(chunk string, fill func(head []byte)) []byte {
+		head := make([]byte, 32)
+		copy(head, "RIFF\x00\x00\x00\x00WEBP"+chunk)
+		fill(head)
+		return head
+	}
+	vp8x := webpHead("VP8X", func(head []byte) {
+		copy(head[24:], []byte{0x7F, 0x07, 0x00, 0x37, 0x04, 0x00}) // 1920x1080 minus one
+	})
+	vp8 := webpHead("VP8 ", func(head []byte) {
+		copy(head[23:], []byte{0x9D, 0x01, 0x2A})
+		binary.LittleEndian.PutUint16(head[26:], 640|0x4000) // scale bits aren't part of the size
+		binary.LittleEndian.PutUint16(head[28:], 480)
+	})
+	vp8l := webpHead("VP8L", func(head []byte) {
+		head[20] = 0x2F
+		binary.LittleEndian.PutUint32(head[21:], uint32(99|(49<<14)))
+	})
+
+	ftyp := mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	mp4 := append(append(append([]byte{}, ftyp...), mp4Box("mdat", make([]byte, 16))...),
+		mp4Box("moov", mp4Box("mvhd", make([]byte, 100)),
+			mp4Box("trak", mp4Tkhd(0, 0, 0)), // audio first, no size
+			mp4Box("trak", mp4Tkhd(0, 1280, 720)))...)
+	mov := append(append([]byte{}, mp4Box("ftyp", []byte("qt  \x00\x00\x00\x00"))...),
+		mp4Box("moov", mp4Box("trak", mp4Tkhd(1, 3840, 2160)))...)
+
+	header := ebmlElement([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebmlElement([]byte{0x42, 0x82}, []byte("webm")))
+	tracks := ebmlElement([]byte{0x16, 0x54, 0xAE, 0x6B},
+		ebmlElement([]byte{0xAE},
+			ebmlElement([]byte{0xE0},
+				ebmlElement([]byte{0xB0}, []byte{0x07, 0x80}),
+				ebmlElement([]byte{0xBA}, []byte{0x04, 0x38}))))
+	// Live recordings leave the segment's size unknown
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, tracks...)
+	webm := append(append([]byte{}, header...), segment...)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+	}{
+		{"png", pngData.Bytes(), 40, 30},
+		{"webp extended", vp8x, 1920, 1080},
+		{"webp lossy", vp8, 640, 480},
+		{"webp lossless", vp8l, 100, 50},
+		{"mp4", mp4, 1280, 720},
+		{"mov version 1", mov, 3840, 2160},
+		{"webm", webm, 1920, 1080},
+	}
+	for _, test := range tests {
+		width, height, err := getMediaDimensions(writeTestMedia(t, test.name, test.data))
+		if err != nil || width != test.width || height != test.height {
+			t.Errorf("%s: %dx%d, %v, want %dx%d", test.name, width, height, err, test.width, test.height)
+		}
+	}
+}
+
+func TestGetMediaDimensionsUnknown(t *testing.T) {
+	truncated := mp4Box("moov", mp4Box("trak", mp4Tkhd(0, 1280, 720)))
+	binary.BigEndian.PutUint32(truncated, uint32(len(truncated)+100)) // box runs past the end of the file
+	for name, data := range map[string][]byte{
+		"text":          []byte("nothing to see here, just some text that goes on"),
+		"truncated mp4": append(mp4Box("ftyp", []byte("isom\x00\x00\x00\x00")), truncated...),
+		"empty webm":    append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}, make([]byte, 32)...),
+	} {
+		if width, height, err := getMediaDimensions(writeTestMedia(t, "unknown", data)); err == nil {
+			t.Errorf("%s: %dx%d, want an error", name, width, height)
+		}
+	}
+}
+
+func TestSizeAndDimensionFilters(t *testing.T) {
+	minSize, maxSize := "1KB", "2 MB"
+	minWidth, maxHeight := 100, 1000
+	minRatio, maxRatio := 0.5, 2.0
+	filters := &configurationSourceFilters{
+		MinFileSize: &minSize, MaxFileSize: &maxSize,
+		MinWidth: &minWidth, MaxHeight: &maxHeight,
+		MinAspectRatio: &minRatio, MaxAspectRatio: &maxRatio,
+	}
+	sizes := map[int64]downloadStatus{
+		999:       downloadSkippedFileSizeTooSmall,
+		1000:      downloadSuccess,
+		2_000_000: downloadSuccess,
+		2_000_001: downloadSkippedFileSizeTooLarge,
+	}
+	for size, want := range sizes {
+		if got := checkFileSizeFilters(filters, size); got != want {
+			t.Errorf("%d bytes: %s, want %s", size, getDownloadStatus(got), getDownloadStatus(want))
+		}
+	}
+	dimensions := []struct {
+		width, height int
+		want          downloadStatus
+	}{
+		{500, 500, downloadSuccess},
+		{99, 99, downloadSkippedDimensionsTooSmall},
+		{800, 1001, downloadSkippedDimensionsTooLarge},
+		{1000, 400, downloadSkippedAspectRatio},
+		{200, 500, downloadSkippedAspectRatio},
+		{1000, 0, downloadSuccess}, // no ratio without a height
+	}
+	for _, test := range dimensions {
+		if got := checkDimensionFilters(filters, test.width, test.height); got != test.want {
+			t.Errorf("%dx%d: %s, want %s", test.width, test.height, getDownloadStatus(got), getDownloadStatus(test.want))
+		}
+	}
+	if !hasDimensionFilters(filters) || hasDimensionFilters(&configurationSourceFilters{MinFileSize: &minSize}) {
+		t.Error("hasDimensionFilters")
+	}
+}
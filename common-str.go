@@ -82,6 +82,13 @@ func pluralS(num int) string {
 	return "s"
 }
 
+func truncateString(i string, l int) string {
+	if runes := []rune(i); len(runes) > l {
+		return string(runes[:l]) + "..."
+	}
+	return i
+}
+
 func wrapHyphens(i string, l int) string {
 	n := i
 	if len(n) < l {
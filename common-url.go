@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/fatih/color"
+)
+
+// Canonical URLs, so the many spellings of one link (mirrors, mobile sites, tracking parameters,
+// short links) are matched by extractors & deduplicated as one. Links are still fetched & stored as
+// they were posted. Rewrites and tracking removal are cheap and done everywhere, resolving short
+// links takes a request so it's opt-in.
+
+type urlRewrite struct {
+	regex   *regexp.Regexp
+	replace string
+}
+
+// Applied in order before any from settings.
+var urlRewrites = []urlRewrite{
+	// Twitter / X and its embed fixers
+	{regexp.MustCompile(`^https?:\/\/((www|mobile|m|c)\.)?(twitter|x|fxtwitter|vxtwitter|fixupx|fixvx|twittpr)\.com\/`), "https://twitter.com/"},
+	// Instagram embed fixers
+	{regexp.MustCompile(`^https?:\/\/((www|m)\.)?(ddinstagram|instagramez|kkinstagram)\.com\/`), "https://www.instagram.com/"},
+	// Reddit's other frontends
+	{regexp.MustCompile(`^https?:\/\/(old|new|np|m|i)\.reddit\.com\/`), "https://www.reddit.com/"},
+	{regexp.MustCompile(`^https?:\/\/reddit\.com\/`), "https://www.reddit.com/"},
+}
+
+// Removed from every URL, utm_ parameters by prefix.
+var trackingParams = []string{
+	"fbclid", "gclid", "dclid", "msclkid", "yclid", "twclid", "igshid", "igsh",
+	"mc_cid", "mc_eid", "_ga", "_gl", "ref_src", "ref_url",
+}
+
+// Removed only on these sites, where they're share tracking rather than part of the link.
+var trackingParamsByDomain = map[string][]string{
+	"twitter.com":      {"s", "t"},
+	"youtube.com":      {"si", "feature", "pp"},
+	"youtu.be":         {"si", "feature"},
+	"open.spotify.com": {"si"},
+	"reddit.com":       {"share_id", "rdt"},
+	"tiktok.com":       {"is_from_webapp", "sender_device", "_r", "_t"},
+}
+
+var shortLinkDomains = []string{
+	"t.co", "bit.ly", "tinyurl.com", "goo.gl", "ow.ly", "buff.ly", "dlvr.it", "is.gd",
+	"trib.al", "tiny.cc", "shorturl.at", "rb.gy", "cutt.ly", "lnkd.in", "spoti.fi",
+}
+
+const (
+	shortLinkTimeout  = 15 * time.Second
+	shortLinkCacheTTL = time.Hour
+	shortLinkCacheMax = 1000
+)
+
+type shortLinkCacheEntry struct {
+	resolved string
+	expires  time.Time
+}
+
+var (
+	urlRewriteMutex   sync.Mutex
+	urlRewriteRegexes = map[string]*regexp.Regexp{}
+
+	shortLinkMutex sync.Mutex
+	shortLinkCache = map[string]shortLinkCacheEntry{}
+)
+
+// canonicalURL is the form links are looked up by in the database, alongside the URL as it was fetched.
+func canonicalURL(link string) string {
+	link = rewriteURL(link)
+	if parsed, err := url.Parse(link); err == nil && isDiscordCDNHost(parsed.Hostname()) {
+		parsed.RawQuery = "" // signatures, different on every fetch
+		return parsed.String()
+	}
+	return link
+}
+
+func isDiscordCDNHost(host string) bool {
+	return host == "cdn.discordapp.com" || host == "media.discordapp.net"
+}
+
+// rewriteURL applies rewrites & drops tracking parameters, without any requests.
+func rewriteURL(link string) string {
+	for _, rewrite := range urlRewrites {
+		link = rewrite.regex.ReplaceAllString(link, rewrite.replace)
+	}
+	for _, rewrite := range config.URLRewrites {
+		if regex := getURLRewriteRegex(rewrite.Pattern); regex != nil {
+			link = regex.ReplaceAllString(link, rewrite.Replace)
+		}
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return link
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	if config.StripTrackingParams && parsed.RawQuery != "" {
+		parsed.RawQuery = stripTrackingParams(parsed.Hostname(), parsed.RawQuery)
+	}
+	return parsed.String()
+}
+
+// Goes through the raw query so whatever's left keeps its order & encoding, some hosts sign them.
+func stripTrackingParams(host string, rawQuery string) string {
+	remove := append(append([]string{}, trackingParams...), config.TrackingParams...)
+	for domain, params := range trackingParamsByDomain {
+		if hostInDomains(host, []string{domain}) {
+			remove = append(remove, params...)
+		}
+	}
+	var kept []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		key := pair
+		if index := strings.Index(pair, "="); index != -1 {
+			key = pair[:index]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if pair == "" || strings.HasPrefix(strings.ToLower(key), "utm_") || stringInSlice(key, remove) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+func getURLRewriteRegex(pattern string) *regexp.Regexp {
+	urlRewriteMutex.Lock()
+	defer urlRewriteMutex.Unlock()
+	regex, exists := urlRewriteRegexes[pattern]
+	if !exists {
+		var err error
+		if regex, err = regexp.Compile(pattern); err != nil {
+			log.Println(lg("Settings", "URL Rewrites", color.HiRedString, "Invalid pattern \"%s\": %s", pattern, err))
+		}
+		urlRewriteRegexes[pattern] = regex // nil for invalid, only complain once
+	}
+	return regex
+}
+
+// canonicalizeLink is rewriteURL plus following short links when enabled, for matching extractors.
+func canonicalizeLink(ctx context.Context, link string) string {
+	link = rewriteURL(link)
+	if config.ResolveShortLinks && isShortLink(link) {
+		link = rewriteURL(resolveShortLink(ctx, link))
+	}
+	return link
+}
+
+func isShortLink(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return hostInDomains(parsed.Hostname(), shortLinkDomains) || hostInDomains(parsed.Hostname(), config.ShortLinkDomains)
+}
+
+// resolveShortLink follows the redirects, returning the link unchanged when that fails.
+func resolveShortLink(ctx context.Context, link string) string {
+	if resolved, cached := getCachedShortLink(link); cached {
+		return resolved
+	}
+
+	request, err := http.NewRequestWithContext(withRequestTimeout(ctx, shortLinkTimeout), "GET", link, nil)
+	if err != nil {
+		return link
+	}
+	response, err := doRequest(request)
+	if err != nil {
+		log.Println(lg("Download", "Short Link", color.RedString, "Failed to resolve %s: %s", link, err))
+		return link
+	}
+	defer response.Body.Close()
+	resolved := response.Request.URL.String()
+
+	// Some (t.co for browsers) answer with a page that redirects instead
+	if isShortLink(resolved) && strings.Contains(response.Header.Get("Content-Type"), "html") {
+		if doc, err := goquery.NewDocumentFromReader(response.Body); err == nil {
+			refresh := ""
+			doc.Find("meta[http-equiv]").Each(func(_ int, meta *goquery.Selection) {
+				if strings.EqualFold(meta.AttrOr("http-equiv", ""), "refresh") {
+					refresh = meta.AttrOr("content", "")
+				}
+			})
+			if index := strings.Index(strings.ToLower(refresh), "url="); index != -1 {
+				target := strings.Trim(strings.TrimSpace(refresh[index+4:]), `"'`)
+				if parsed, err := response.Request.URL.Parse(target); err == nil {
+					resolved = parsed.String()
+				}
+			}
+		}
+	}
+
+	cacheShortLink(link, resolved)
+	return resolved
+}
+
+func getCachedShortLink(link string) (string, bool) {
+	shortLinkMutex.Lock()
+	defer shortLinkMutex.Unlock()
+	entry, cached := shortLinkCache[link]
+	if !cached || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.resolved, true
+}
+
+// Expired entries go first once the cache is full, then whichever would expire soonest.
+func cacheShortLink(link string, resolved string) {
+	shortLinkMutex.Lock()
+	defer shortLinkMutex.Unlock()
+	if len(shortLinkCache) >= shortLinkCacheMax {
+		now := time.Now()
+		oldest := ""
+		for key, entry := range shortLinkCache {
+			if now.After(entry.expires) {
+				delete(shortLinkCache, key)
+			} else if oldest == "" || entry.expires.Before(shortLinkCache[oldest].expires) {
+				oldest = key
+			}
+		}
+		if len(shortLinkCache) >= shortLinkCacheMax {
+			delete(shortLinkCache, oldest)
+		}
+	}
+	shortLinkCache[link] = shortLinkCacheEntry{resolved: resolved, expires: time.Now().Add(shortLinkCacheTTL)}
+}
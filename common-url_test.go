@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	config = defaultConfiguration()
+	tests := map[string]string{
+		"https://x.com/user/status/1?s=20&t=abc":                        "https://twitter.com/user/status/1",
+		"https://fxtwitter.com/user/status/1":                           "https://twitter.com/user/status/1",
+		"https://old.reddit.com/r/pics/comments/a/b/?share_id=x":        "https://www.reddit.com/r/pics/comments/a/b/",
+		"https://www.ddinstagram.com/p/abc/?igsh=x":                     "https://www.instagram.com/p/abc/",
+		"https://example.com/a?utm_source=x&b=2&fbclid=y&a=1":           "https://example.com/a?b=2&a=1",
+		"https://example.com/a?s=keep":                                  "https://example.com/a?s=keep",
+		"https://EXAMPLE.com/Path":                                      "https://example.com/Path",
+		"https://cdn.discordapp.com/attachments/1/2/a.png?ex=1&hm=2":    "https://cdn.discordapp.com/attachments/1/2/a.png",
+		"https://media.discordapp.net/attachments/1/2/a.png?ex=1&is=2":  "https://media.discordapp.net/attachments/1/2/a.png",
+		"https://example.com/signed?X-Amz-Signature=a%2Fb&X-Amz-Date=1": "https://example.com/signed?X-Amz-Signature=a%2Fb&X-Amz-Date=1",
+	}
+	for link, want := range tests {
+		if got := canonicalURL(link); got != want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", link, got, want)
+		}
+	}
+
+	config.StripTrackingParams = false
+	if got := canonicalURL("https://example.com/a?utm_source=x"); got != "https://example.com/a?utm_source=x" {
+		t.Errorf("tracking stripped while disabled: %q", got)
+	}
+	config.URLRewrites = []configurationURLRewrite{{Pattern: `^https://mirror\.test/`, Replace: "https://example.com/"}}
+	if got := canonicalURL("https://mirror.test/a"); got != "https://example.com/a" {
+		t.Errorf("rewrite from settings: %q", got)
+	}
+}
+
+func TestResolveShortLinkCache(t *testing.T) {
+	config = defaultConfiguration()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, "/long", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		if got := resolveShortLink(context.Background(), server.URL+"/short"); got != server.URL+"/long" {
+			t.Fatalf("resolved to %s", got)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("%d requests, the second resolve should've been cached", requests)
+	}
+
+	shortLinkMutex.Lock()
+	shortLinkCache[server.URL+"/short"] = shortLinkCacheEntry{resolved: "stale", expires: time.Now().Add(-time.Second)}
+	shortLinkMutex.Unlock()
+	if got := resolveShortLink(context.Background(), server.URL+"/short"); got != server.URL+"/long" {
+		t.Errorf("expired entry used: %s", got)
+	}
+}
+
+func TestShortLinkCacheBounded(t *testing.T) {
+	shortLinkMutex.Lock()
+	shortLinkCache = map[string]shortLinkCacheEntry{}
+	shortLinkMutex.Unlock()
+	for i := 0; i < shortLinkCacheMax+10; i++ {
+		cacheShortLink(fmt.Sprintf("https://t.co/%d", i), "https://example.com/")
+	}
+	if len(shortLinkCache) > shortLinkCacheMax {
+		t.Errorf("cache grew to %d entries", len(shortLinkCache))
+	}
+	if _, cached := getCachedShortLink(fmt.Sprintf("https://t.co/%d", shortLinkCacheMax+9)); !cached {
+		t.Error("newest entry was evicted")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -26,25 +27,24 @@ func properExit() {
 	os.Exit(1)
 }
 
-func getJSON(url string, target interface{}) error {
-	r, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
+// Longest an API call can take once the domain's limits let it through.
+const jsonRequestTimeout = 30 * time.Second
 
-	return json.NewDecoder(r.Body).Decode(target)
+func getJSON(ctx context.Context, url string, target interface{}) error {
+	return getJSONwithHeaders(ctx, url, target, nil)
 }
 
-func getJSONwithHeaders(url string, target interface{}, headers map[string]string) error {
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", url, nil)
+func getJSONwithHeaders(ctx context.Context, url string, target interface{}, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(withRequestTimeout(ctx, jsonRequestTimeout), "GET", url, nil)
+	if err != nil {
+		return err
+	}
 
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 
-	r, err := client.Do(req)
+	r, err := doRequest(req)
 	if err != nil {
 		return err
 	}
@@ -63,7 +63,7 @@ var latestGithubRelease string
 
 func getLatestGithubRelease() string {
 	githubReleaseApiObject := new(githubReleaseApiObject)
-	err := getJSON("https://api.github.com/repos/"+projectRepoBase+"/releases/latest", githubReleaseApiObject)
+	err := getJSON(context.Background(), "https://api.github.com/repos/"+projectRepoBase+"/releases/latest", githubReleaseApiObject)
 	if err != nil {
 		log.Println(lg("API", "Github", color.RedString, "Error fetching current Release JSON: %s", err))
 		return ""
@@ -50,6 +50,10 @@ type configurationCredentials struct {
 	InstagramProxyInsecure   *bool   `json:"instagramProxyInsecure,omitempty" yaml:"instagramProxyInsecure,omitempty"`
 	InstagramProxyForceHTTP2 *bool   `json:"instagramProxyForceHTTP2,omitempty" yaml:"instagramProxyForceHTTP2,omitempty"`
 	FlickrApiKey             string  `json:"flickrApiKey" yaml:"flickrApiKey"`
+	DanbooruUsername         string  `json:"danbooruUsername,omitempty" yaml:"danbooruUsername,omitempty"`
+	DanbooruApiKey           string  `json:"danbooruApiKey,omitempty" yaml:"danbooruApiKey,omitempty"`
+	GelbooruUserID           string  `json:"gelbooruUserID,omitempty" yaml:"gelbooruUserID,omitempty"`
+	GelbooruApiKey           string  `json:"gelbooruApiKey,omitempty" yaml:"gelbooruApiKey,omitempty"`
 }
 
 //#endregion
@@ -79,6 +83,7 @@ var (
 	defConfig_DiscordTimeout   int = 180
 	defConfig_DownloadTimeout  int = 60
 	defConfig_DownloadRetryMax int = 2
+	defConfig_DownloadWorkers  int = 3
 
 	defConfig_HistoryManagerRate  int = 5
 	defConfig_CheckupRate         int = 30
@@ -87,8 +92,13 @@ var (
 
 	defConfig_FilenameDateFormat string = "2006-01-02_15-04-05"
 	defConfig_FilenameFormat     string = "{{date}} {{file}}"
+	defConfig_GIFFormat          string = "gif"
 
 	defConfig_HistoryMaxJobs int = 3
+
+	defConfig_FailedDownloadSweepAge int = 60
+
+	defConfig_MessageLinkDepth int = 1
 )
 
 func defaultConfiguration() configuration {
@@ -121,6 +131,7 @@ func defaultConfiguration() configuration {
 		DiscordTimeout:       defConfig_DiscordTimeout,
 		DownloadTimeout:      defConfig_DownloadTimeout,
 		DownloadRetryMax:     defConfig_DownloadRetryMax,
+		DownloadWorkers:      defConfig_DownloadWorkers,
 		ExitOnBadConnection:  false,
 		GithubUpdateChecking: defConfig_GithubUpdateChecking,
 
@@ -136,15 +147,33 @@ func defaultConfiguration() configuration {
 		ConnectionCheckRate: defConfig_ConnectionCheckRate,
 		PresenceRefreshRate: defConfig_PresenceRefreshRate,
 
+		// Networking
+		DomainLimits: []configurationDomainLimit{
+			{
+				Domains:           []string{"reddit.com", "redd.it"},
+				RequestsPerSecond: 0.5,
+				Burst:             5,
+				MaxConnections:    2,
+			},
+			{
+				Domains:           []string{"imgur.com"},
+				RequestsPerSecond: 1,
+				Burst:             5,
+				MaxConnections:    4,
+			},
+		},
+
 		// Emojis & Stickers
 		EmojisFilenameFormat:   "{{ID}} {{name}}",
 		StickersFilenameFormat: "{{ID}} {{name}}",
 
 		// Source Setup Defaults
-		Save:          true,
-		AllowCommands: true,
-		ScanEdits:     true,
-		IgnoreBots:    true,
+		Save:            true,
+		AllowCommands:   true,
+		ScanEdits:       true,
+		IncludeReplies:  false,
+		IncludeForwards: false,
+		IgnoreBots:      true,
 
 		SendErrorMessages: false,
 		SendFileToChannel: "",
@@ -169,18 +198,32 @@ func defaultConfiguration() configuration {
 		HistoryRequestCount:   100,
 		HistoryRequestDelay:   0,
 
+		// Failed Downloads
+		FailedDownloadSweepRate: 0,
+		FailedDownloadSweepAge:  defConfig_FailedDownloadSweepAge,
+
+		MessageLinkDepth: defConfig_MessageLinkDepth,
+
+		StripTrackingParams: true,
+		ResolveShortLinks:   false,
+
 		// Rules for Saving
 		Subfolders:             []string{"{{fileType}}"},
 		FilenameDateFormat:     defConfig_FilenameDateFormat,
 		FilenameFormat:         defConfig_FilenameFormat,
 		FilepathNormalizeText:  true,
 		FilepathStripSymbols:   false,
+		FixExtensions:          false,
+		UsePostTime:            false,
+		GIFFormat:              defConfig_GIFFormat,
 		SaveImages:             true,
 		SaveVideos:             true,
 		SaveAudioFiles:         true,
 		SaveTextFiles:          false,
 		SaveOtherFiles:         false,
 		SavePossibleDuplicates: false,
+		DuplicateContent:       "keep",
+		DuplicateContentScope:  "global",
 		DelayHandling:          0,
 		DelayHandlingHistory:   0,
 		Filters: &configurationSourceFilters{
@@ -247,6 +290,8 @@ type configuration struct {
 
 	// Discord
 	ScanEdits            bool   `json:"scanEdits" yaml:"scanEdits"`
+	IncludeReplies       bool   `json:"includeReplies,omitempty" yaml:"includeReplies,omitempty"`   // files of the message replied to
+	IncludeForwards      bool   `json:"includeForwards,omitempty" yaml:"includeForwards,omitempty"` // files of forwarded messages
 	IgnoreBots           bool   `json:"ignoreBots" yaml:"ignoreBots"`
 	ScanOwnMessages      bool   `json:"scanOwnMessages" yaml:"scanOwnMessages"`
 	AllowCommands        bool   `json:"allowCommands" yaml:"allowCommands"`
@@ -256,6 +301,7 @@ type configuration struct {
 	DiscordTimeout       int    `json:"discordTimeout" yaml:"discordTimeout"`
 	DownloadTimeout      int    `json:"downloadTimeout" yaml:"downloadTimeout"`
 	DownloadRetryMax     int    `json:"downloadRetryMax" yaml:"downloadRetryMax"`
+	DownloadWorkers      int    `json:"downloadWorkers" yaml:"downloadWorkers"` // requires restart
 	SendErrorMessages    bool   `json:"sendErrorMessages" yaml:"sendErrorMessages"`
 
 	// Discord Emojis & Stickers
@@ -299,6 +345,10 @@ type configuration struct {
 	HistoryRequestCount   int    `json:"historyRequestCount" yaml:"historyRequestCount"`
 	HistoryRequestDelay   int    `json:"historyRequestDelay" yaml:"historyRequestDelay"`
 
+	// Failed Downloads
+	FailedDownloadSweepRate int `json:"failedDownloadSweepRate,omitempty" yaml:"failedDownloadSweepRate,omitempty"` // minutes, 0 to disable
+	FailedDownloadSweepAge  int `json:"failedDownloadSweepAge,omitempty" yaml:"failedDownloadSweepAge,omitempty"`   // minutes since last attempt
+
 	// Rules for Saving
 	Save                   bool                        `json:"save" yaml:"save"`
 	Subfolders             []string                    `json:"subfolders" yaml:"subfolders"`
@@ -306,6 +356,9 @@ type configuration struct {
 	FilenameFormat         string                      `json:"filenameFormat" yaml:"filenameFormat"`
 	FilepathNormalizeText  bool                        `json:"filepathNormalizeText,omitempty" yaml:"filepathNormalizeText,omitempty"`
 	FilepathStripSymbols   bool                        `json:"filepathStripSymbols,omitempty" yaml:"filepathStripSymbols,omitempty"`
+	FixExtensions          bool                        `json:"fixExtensions,omitempty" yaml:"fixExtensions,omitempty"` // rename to match detected content
+	UsePostTime            bool                        `json:"usePostTime,omitempty" yaml:"usePostTime,omitempty"`     // file times from when linked posts were made, where known
+	GIFFormat              string                      `json:"gifFormat,omitempty" yaml:"gifFormat,omitempty"`         // gif or mp4, for GIF sites that have both
 	SaveImages             bool                        `json:"saveImages" yaml:"saveImages"`
 	SaveVideos             bool                        `json:"saveVideos" yaml:"saveVideos"`
 	SaveAudioFiles         bool                        `json:"saveAudioFiles" yaml:"saveAudioFiles"`
@@ -317,11 +370,41 @@ type configuration struct {
 	Filters                *configurationSourceFilters `json:"filters" yaml:"filters"`
 	Duplo                  bool                        `json:"duplo,omitempty" yaml:"duplo,omitempty"`
 	DuploThreshold         float64                     `json:"duploThreshold,omitempty" yaml:"duploThreshold,omitempty"`
+	DuplicateContent       string                      `json:"duplicateContent,omitempty" yaml:"duplicateContent,omitempty"`           // keep, skip or hardlink
+	DuplicateContentScope  string                      `json:"duplicateContentScope,omitempty" yaml:"duplicateContentScope,omitempty"` // global or source
 
 	// Misc Rules
 	LogLinks    *configurationSourceLog `json:"logLinks,omitempty" yaml:"logLinks,omitempty"`
 	LogMessages *configurationSourceLog `json:"logMessages,omitempty" yaml:"logMessages,omitempty"`
 
+	// Networking
+	DomainLimits        []configurationDomainLimit    `json:"domainLimits,omitempty" yaml:"domainLimits,omitempty"`
+	DownloadRetryPolicy *configurationRetryPolicy     `json:"downloadRetryPolicy,omitempty" yaml:"downloadRetryPolicy,omitempty"`
+	RequestProfiles     []configurationRequestProfile `json:"requestProfiles,omitempty" yaml:"requestProfiles,omitempty"`
+	HTTPTransport       *configurationHTTPTransport   `json:"httpTransport,omitempty" yaml:"httpTransport,omitempty"` // requires restart
+
+	// URLs, see common-url.go
+	URLRewrites         []configurationURLRewrite `json:"urlRewrites,omitempty" yaml:"urlRewrites,omitempty"`
+	StripTrackingParams bool                      `json:"stripTrackingParams" yaml:"stripTrackingParams"`
+	TrackingParams      []string                  `json:"trackingParams,omitempty" yaml:"trackingParams,omitempty"` // on top of the known ones
+	ResolveShortLinks   bool                      `json:"resolveShortLinks,omitempty" yaml:"resolveShortLinks,omitempty"`
+	ShortLinkDomains    []string                  `json:"shortLinkDomains,omitempty" yaml:"shortLinkDomains,omitempty"` // on top of the known ones
+
+	// Extractors, keyed by name (see the extractors command)
+	Extractors         map[string]*configurationExtractor `json:"extractors,omitempty" yaml:"extractors,omitempty"`
+	ExternalExtractors []configurationExternalExtractor   `json:"externalExtractors,omitempty" yaml:"externalExtractors,omitempty"`
+	// Pages on these domains (subdomains included) get their media from link preview tags when nothing else handled them
+	MetaExtractorDomains []string `json:"metaExtractorDomains,omitempty" yaml:"metaExtractorDomains,omitempty"`
+	// Media from quoted tweets, and from the earlier tweets of a thread by the same author
+	TwitterQuotedTweets bool `json:"twitterQuotedTweets,omitempty" yaml:"twitterQuotedTweets,omitempty"`
+	TwitterThreads      bool `json:"twitterThreads,omitempty" yaml:"twitterThreads,omitempty"`
+	// How many links deep to follow Discord message links to other messages, 0 to leave them alone
+	MessageLinkDepth int `json:"messageLinkDepth" yaml:"messageLinkDepth"`
+	// Used to merge separate audio & video streams, looked up on PATH when empty
+	FFmpegPath string `json:"ffmpegPath,omitempty" yaml:"ffmpegPath,omitempty"`
+	// Used to unpack 7z archives, 7z/7zz/7za are looked up on PATH when empty
+	SevenZipPath string `json:"sevenZipPath,omitempty" yaml:"sevenZipPath,omitempty"`
+
 	// Sources
 	All                    *configurationSource  `json:"all,omitempty" yaml:"all,omitempty"`
 	AllBlacklistUsers      *[]string             `json:"allBlacklistUsers,omitempty" yaml:"allBlacklistUsers,omitempty"`
@@ -334,6 +417,69 @@ type configuration struct {
 	Channels               []configurationSource `json:"channels,omitempty" yaml:"channels,omitempty"`
 }
 
+// Anything left unset falls back to the global policy, then to downloadRetryMax & downloadTimeout.
+// Codes are HTTP statuses or classes like "5xx". Failures without a status (dropped connections,
+// timeouts) are always retried.
+type configurationRetryPolicy struct {
+	MaxAttempts     *int      `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	Timeout         *int      `json:"timeout,omitempty" yaml:"timeout,omitempty"`       // seconds per attempt
+	Backoff         *float64  `json:"backoff,omitempty" yaml:"backoff,omitempty"`       // seconds before the first retry, doubled every retry after
+	BackoffMax      *float64  `json:"backoffMax,omitempty" yaml:"backoffMax,omitempty"` // seconds
+	Jitter          *float64  `json:"jitter,omitempty" yaml:"jitter,omitempty"`         // 0-1, share of the delay to randomize
+	RetryCodes      *[]string `json:"retryCodes,omitempty" yaml:"retryCodes,omitempty"` // only retry these, all when unset
+	NeverRetryCodes *[]string `json:"neverRetryCodes,omitempty" yaml:"neverRetryCodes,omitempty"`
+}
+
+// Limits apply per listed domain (subdomains included), each domain keeps its own bucket & connection count.
+type configurationDomainLimit struct {
+	Domains           []string `json:"domains" yaml:"domains"`
+	RequestsPerSecond float64  `json:"requestsPerSecond,omitempty" yaml:"requestsPerSecond,omitempty"` // token refill rate, 0 for no limit
+	Burst             int      `json:"burst,omitempty" yaml:"burst,omitempty"`                         // bucket size
+	MaxConnections    int      `json:"maxConnections,omitempty" yaml:"maxConnections,omitempty"`
+	MinDelay          int      `json:"minDelay,omitempty" yaml:"minDelay,omitempty"` // milliseconds between requests
+}
+
+// Profiles apply to every request whose host is in Domains (subdomains included) or whose URL matches URLRegex.
+// When several match they're applied in order, so later profiles win.
+type configurationRequestProfile struct {
+	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Domains     []string          `json:"domains,omitempty" yaml:"domains,omitempty"`
+	URLRegex    string            `json:"urlRegex,omitempty" yaml:"urlRegex,omitempty"`
+	UserAgent   string            `json:"userAgent,omitempty" yaml:"userAgent,omitempty"`
+	Referer     string            `json:"referer,omitempty" yaml:"referer,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	CookiesFile string            `json:"cookiesFile,omitempty" yaml:"cookiesFile,omitempty"` // Netscape cookies.txt
+	Proxy       string            `json:"proxy,omitempty" yaml:"proxy,omitempty"`             // http(s):// or socks5://, "direct" to bypass
+}
+
+type configurationExtractor struct {
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// yt-dlp or gallery-dl, only ever run for links on the listed domains (subdomains included).
+type configurationExternalExtractor struct {
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"` // for the extractors list & settings, defaults to the tool
+	Tool    string   `json:"tool" yaml:"tool"`                     // "yt-dlp" or "gallery-dl"
+	Path    string   `json:"path,omitempty" yaml:"path,omitempty"` // binary location, found on PATH when unset
+	Domains []string `json:"domains" yaml:"domains"`
+	Args    []string `json:"args,omitempty" yaml:"args,omitempty"`       // extra arguments, before the link
+	Mode    string   `json:"mode,omitempty" yaml:"mode,omitempty"`       // "urls" (default) or "direct" to let the tool download
+	Timeout int      `json:"timeout,omitempty" yaml:"timeout,omitempty"` // seconds, 120 by default
+}
+
+// Tuning for the connection pool shared by every download & link lookup, anything unset keeps the defaults.
+type configurationHTTPTransport struct {
+	MaxIdleConns          *int  `json:"maxIdleConns,omitempty" yaml:"maxIdleConns,omitempty"`
+	MaxIdleConnsPerHost   *int  `json:"maxIdleConnsPerHost,omitempty" yaml:"maxIdleConnsPerHost,omitempty"`
+	MaxConnsPerHost       *int  `json:"maxConnsPerHost,omitempty" yaml:"maxConnsPerHost,omitempty"`
+	IdleConnTimeout       *int  `json:"idleConnTimeout,omitempty" yaml:"idleConnTimeout,omitempty"` // seconds
+	KeepAlive             *int  `json:"keepAlive,omitempty" yaml:"keepAlive,omitempty"`             // seconds, negative to disable
+	DialTimeout           *int  `json:"dialTimeout,omitempty" yaml:"dialTimeout,omitempty"`         // seconds
+	TLSHandshakeTimeout   *int  `json:"tlsHandshakeTimeout,omitempty" yaml:"tlsHandshakeTimeout,omitempty"`
+	TLSInsecureSkipVerify *bool `json:"tlsInsecureSkipVerify,omitempty" yaml:"tlsInsecureSkipVerify,omitempty"`
+	DisableHTTP2          *bool `json:"disableHTTP2,omitempty" yaml:"disableHTTP2,omitempty"`
+}
+
 //#endregion
 
 //#region Config, Sources
@@ -360,12 +506,14 @@ type configurationSource struct {
 	Aliases           *[]string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
 
 	// Setup
-	Enabled       *bool   `json:"enabled" yaml:"enabled"`
-	Save          *bool   `json:"save" yaml:"save"`
-	AllowCommands *bool   `json:"allowCommands" yaml:"allowCommands"`
-	ScanEdits     *bool   `json:"scanEdits" yaml:"scanEdits"`
-	IgnoreBots    *bool   `json:"ignoreBots" yaml:"ignoreBots"`
-	CommandPrefix *string `json:"commandPrefix" yaml:"commandPrefix"`
+	Enabled         *bool   `json:"enabled" yaml:"enabled"`
+	Save            *bool   `json:"save" yaml:"save"`
+	AllowCommands   *bool   `json:"allowCommands" yaml:"allowCommands"`
+	ScanEdits       *bool   `json:"scanEdits" yaml:"scanEdits"`
+	IncludeReplies  *bool   `json:"includeReplies,omitempty" yaml:"includeReplies,omitempty"`
+	IncludeForwards *bool   `json:"includeForwards,omitempty" yaml:"includeForwards,omitempty"`
+	IgnoreBots      *bool   `json:"ignoreBots" yaml:"ignoreBots"`
+	CommandPrefix   *string `json:"commandPrefix" yaml:"commandPrefix"`
 
 	SendErrorMessages  *bool     `json:"sendErrorMessages" yaml:"sendErrorMessages"`
 	SendFileToChannel  *string   `json:"sendFileToChannel" yaml:"sendFileToChannel"`
@@ -395,6 +543,9 @@ type configurationSource struct {
 	FilenameFormat         *string                     `json:"filenameFormat" yaml:"filenameFormat"`
 	FilepathNormalizeText  *bool                       `json:"filepathNormalizeText,omitempty" yaml:"filepathNormalizeText,omitempty"`
 	FilepathStripSymbols   *bool                       `json:"filepathStripSymbols,omitempty" yaml:"filepathStripSymbols,omitempty"`
+	FixExtensions          *bool                       `json:"fixExtensions,omitempty" yaml:"fixExtensions,omitempty"`
+	UsePostTime            *bool                       `json:"usePostTime,omitempty" yaml:"usePostTime,omitempty"`
+	GIFFormat              *string                     `json:"gifFormat,omitempty" yaml:"gifFormat,omitempty"`
 	SaveImages             *bool                       `json:"saveImages" yaml:"saveImages"`
 	SaveVideos             *bool                       `json:"saveVideos" yaml:"saveVideos"`
 	SaveAudioFiles         *bool                       `json:"saveAudioFiles" yaml:"saveAudioFiles"`
@@ -406,10 +557,16 @@ type configurationSource struct {
 	Filters                *configurationSourceFilters `json:"filters" yaml:"filters"`
 	Duplo                  *bool                       `json:"duplo,omitempty" yaml:"duplo,omitempty"`
 	DuploThreshold         *float64                    `json:"duploThreshold,omitempty" yaml:"duploThreshold,omitempty"`
+	DuplicateContent       *string                     `json:"duplicateContent,omitempty" yaml:"duplicateContent,omitempty"`
+	DuplicateContentScope  *string                     `json:"duplicateContentScope,omitempty" yaml:"duplicateContentScope,omitempty"`
 
 	// Misc Rules
-	LogLinks    *configurationSourceLog `json:"logLinks,omitempty" yaml:"logLinks,omitempty"`
-	LogMessages *configurationSourceLog `json:"logMessages,omitempty" yaml:"logMessages,omitempty"`
+	LogLinks            *configurationSourceLog   `json:"logLinks,omitempty" yaml:"logLinks,omitempty"`
+	LogMessages         *configurationSourceLog   `json:"logMessages,omitempty" yaml:"logMessages,omitempty"`
+	DownloadRetryPolicy *configurationRetryPolicy `json:"downloadRetryPolicy,omitempty" yaml:"downloadRetryPolicy,omitempty"`
+	Proxy               *string                   `json:"proxy,omitempty" yaml:"proxy,omitempty"` // overrides request profiles for this source's downloads & extractors
+	ScanEmbedText       *configurationEmbedText   `json:"scanEmbedText,omitempty" yaml:"scanEmbedText,omitempty"`
+	ExtractArchives     *configurationArchives    `json:"extractArchives,omitempty" yaml:"extractArchives,omitempty"`
 }
 
 type configurationSourceFilters struct {
@@ -433,6 +590,18 @@ type configurationSourceFilters struct {
 
 	BlockedReactions *[]string `json:"blockedReactions,omitempty" yaml:"blockedReactions,omitempty"`
 	AllowedReactions *[]string `json:"allowedReactions,omitempty" yaml:"allowedReactions,omitempty"`
+
+	// Sizes like "500KB" or "2GiB", plain numbers are bytes
+	MinFileSize *string `json:"minFileSize,omitempty" yaml:"minFileSize,omitempty"`
+	MaxFileSize *string `json:"maxFileSize,omitempty" yaml:"maxFileSize,omitempty"`
+
+	// Images & videos, in pixels. Aspect ratio is width / height
+	MinWidth       *int     `json:"minWidth,omitempty" yaml:"minWidth,omitempty"`
+	MaxWidth       *int     `json:"maxWidth,omitempty" yaml:"maxWidth,omitempty"`
+	MinHeight      *int     `json:"minHeight,omitempty" yaml:"minHeight,omitempty"`
+	MaxHeight      *int     `json:"maxHeight,omitempty" yaml:"maxHeight,omitempty"`
+	MinAspectRatio *float64 `json:"minAspectRatio,omitempty" yaml:"minAspectRatio,omitempty"`
+	MaxAspectRatio *float64 `json:"maxAspectRatio,omitempty" yaml:"maxAspectRatio,omitempty"`
 }
 
 var (
@@ -445,6 +614,26 @@ var (
 	defSourceLog_LogFailures           bool     = true
 )
 
+// Pattern is a regular expression matched against the whole URL, Replace can use its groups ($1).
+type configurationURLRewrite struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Replace string `json:"replace" yaml:"replace"`
+}
+
+// Links in embed text (descriptions, fields, footers) and author links, for feed bots that post media that way.
+type configurationEmbedText struct {
+	Domains  []string `json:"domains" yaml:"domains"`                       // required, subdomains included
+	MaxLinks *int     `json:"maxLinks,omitempty" yaml:"maxLinks,omitempty"` // per message, default 10
+}
+
+// Zip, tar & 7z files are unpacked with each file saved on its own, 7z needs the 7-Zip program.
+type configurationArchives struct {
+	Subfolder    *string `json:"subfolder,omitempty" yaml:"subfolder,omitempty"`       // default {{archiveName}}, empty for none
+	KeepArchive  *bool   `json:"keepArchive,omitempty" yaml:"keepArchive,omitempty"`   // default true
+	MaxTotalSize *string `json:"maxTotalSize,omitempty" yaml:"maxTotalSize,omitempty"` // unpacked, default 2GB
+	MaxFiles     *int    `json:"maxFiles,omitempty" yaml:"maxFiles,omitempty"`         // default 1000
+}
+
 type configurationSourceLog struct {
 	Destination           string    `json:"destination" yaml:"destination"`
 	Subfolders            *[]string `json:"subfolders,omitempty" yaml:"subfolders,omitempty"`
@@ -591,6 +780,12 @@ func loadConfig() error {
 		if config.DownloadRetryMax < 1 {
 			config.DownloadRetryMax = defConfig_DownloadRetryMax
 		}
+		if config.DownloadWorkers < 1 {
+			config.DownloadWorkers = defConfig_DownloadWorkers
+		}
+		if config.FailedDownloadSweepAge < 1 {
+			config.FailedDownloadSweepAge = defConfig_FailedDownloadSweepAge
+		}
 		if config.CheckupRate < 1 {
 			config.CheckupRate = defConfig_CheckupRate
 		}
@@ -666,6 +861,7 @@ func loadConfig() error {
 		// Overwrite Paths
 		if config.OverwriteCachePath != "" {
 			pathCache = config.OverwriteCachePath
+			pathCachePartials = pathCache + string(os.PathSeparator) + "partials"
 		}
 		if config.OverwriteHistoryPath != "" {
 			pathCacheHistory = config.OverwriteHistoryPath
@@ -721,6 +917,18 @@ func loadConfig() error {
 			if dupeConfig.Credentials.FlickrApiKey != "" {
 				dupeConfig.Credentials.FlickrApiKey = "STRIPPED_FOR_OUTPUT"
 			}
+			if dupeConfig.Credentials.DanbooruUsername != "" {
+				dupeConfig.Credentials.DanbooruUsername = "STRIPPED_FOR_OUTPUT"
+			}
+			if dupeConfig.Credentials.DanbooruApiKey != "" {
+				dupeConfig.Credentials.DanbooruApiKey = "STRIPPED_FOR_OUTPUT"
+			}
+			if dupeConfig.Credentials.GelbooruUserID != "" {
+				dupeConfig.Credentials.GelbooruUserID = "STRIPPED_FOR_OUTPUT"
+			}
+			if dupeConfig.Credentials.GelbooruApiKey != "" {
+				dupeConfig.Credentials.GelbooruApiKey = "STRIPPED_FOR_OUTPUT"
+			}
 			s, err := json.MarshalIndent(dupeConfig, "", "\t")
 			if err != nil {
 				log.Println(lg("Debug", "loadConfig", color.HiRedString, "Failed to output...\t%s", err))
@@ -982,6 +1190,12 @@ func sourceDefault(source *configurationSource) {
 	if source.ScanEdits == nil {
 		source.ScanEdits = &config.ScanEdits
 	}
+	if source.IncludeReplies == nil {
+		source.IncludeReplies = &config.IncludeReplies
+	}
+	if source.IncludeForwards == nil {
+		source.IncludeForwards = &config.IncludeForwards
+	}
 	if source.IgnoreBots == nil {
 		source.IgnoreBots = &config.IgnoreBots
 	}
@@ -1058,6 +1272,15 @@ func sourceDefault(source *configurationSource) {
 	if source.FilepathStripSymbols == nil {
 		source.FilepathStripSymbols = &config.FilepathStripSymbols
 	}
+	if source.FixExtensions == nil {
+		source.FixExtensions = &config.FixExtensions
+	}
+	if source.UsePostTime == nil {
+		source.UsePostTime = &config.UsePostTime
+	}
+	if source.GIFFormat == nil {
+		source.GIFFormat = &config.GIFFormat
+	}
 	if source.SaveImages == nil {
 		source.SaveImages = &config.SaveImages
 	}
@@ -1097,6 +1320,12 @@ func sourceDefault(source *configurationSource) {
 	if source.DuploThreshold == nil && config.DuploThreshold != 0 {
 		source.DuploThreshold = &config.DuploThreshold
 	}
+	if source.DuplicateContent == nil {
+		source.DuplicateContent = &config.DuplicateContent
+	}
+	if source.DuplicateContentScope == nil {
+		source.DuplicateContentScope = &config.DuplicateContentScope
+	}
 
 	// Misc Rules
 	if source.LogLinks != nil {
@@ -1193,6 +1422,22 @@ func isBotAdmin(m *discordgo.Message) bool {
 
 var emptySourceConfig configurationSource = configurationSource{}
 
+// getSourceKey identifies a source by what it's set up to cover, the same across copies of it & settings reloads.
+func getSourceKey(source configurationSource) string {
+	ids := func(single string, list *[]string) string {
+		if list == nil {
+			return single
+		}
+		return single + "," + strings.Join(*list, ",")
+	}
+	return strings.Join([]string{
+		ids(source.UserID, source.UserIDs),
+		ids(source.ServerID, source.ServerIDs),
+		ids(source.CategoryID, source.CategoryIDs),
+		ids(source.ChannelID, source.ChannelIDs),
+	}, "|")
+}
+
 func getSource(m *discordgo.Message) configurationSource {
 	chinfo, err := bot.State.Channel(m.ChannelID)
 	if err != nil {
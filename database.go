@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/HouzuoGuo/tiedot/db"
@@ -46,8 +47,30 @@ func openDatabase() {
 		indexColumn("URL")
 		indexColumn("ChannelID")
 		indexColumn("UserID")
+		indexColumn("Hash")
 		log.Println(lg("Database", "Setup", color.HiYellowString, "Created database structure...\t(took %s)", timeSinceShort(createT)))
 	}
+	// Databases from before content hashing & canonical URLs
+	for _, column := range []string{"Hash", "CanonicalURL"} {
+		indexed := false
+		for _, index := range myDB.Use("Downloads").AllIndexes() {
+			if len(index) == 1 && index[0] == column {
+				indexed = true
+			}
+		}
+		if !indexed {
+			if err := myDB.Use("Downloads").Index([]string{column}); err != nil {
+				log.Println(lg("Database", "Setup", color.HiRedString, "Unable to create index for %s: %s", column, err))
+			}
+		}
+	}
+	if myDB.Use("Failures") == nil {
+		if err := myDB.Create("Failures"); err != nil {
+			log.Println(lg("Database", "Setup", color.HiRedString, "Error while trying to create failure queue: %s", err))
+		} else if err := myDB.Use("Failures").Index([]string{"URL"}); err != nil {
+			log.Println(lg("Database", "Setup", color.HiRedString, "Unable to create index for failure queue: %s", err))
+		}
+	}
 	// Cache download tally
 	cachedDownloadID = dbDownloadCount()
 	log.Println(lg("Database", "", color.HiYellowString, "Database opened, contains %d entries...\t(took %s)", cachedDownloadID, timeSinceShort(openT)))
@@ -127,12 +150,16 @@ func backupDatabase() error {
 
 func dbInsertDownload(download *downloadItem) error {
 	_, err := myDB.Use("Downloads").Insert(map[string]interface{}{
-		"URL":         download.URL,
-		"Time":        download.Time.String(),
-		"Destination": download.Destination,
-		"Filename":    download.Filename,
-		"ChannelID":   download.ChannelID,
-		"UserID":      download.UserID,
+		"URL":          download.URL,
+		"CanonicalURL": canonicalURL(download.URL),
+		"Time":         download.Time.String(),
+		"Destination":  download.Destination,
+		"Filename":     download.Filename,
+		"ChannelID":    download.ChannelID,
+		"UserID":       download.UserID,
+		"Hash":         download.Hash,
+
+		"OriginMessageID": download.OriginMessageID,
 	})
 	return err
 }
@@ -144,6 +171,9 @@ func dbFindDownloadByID(id int) *downloadItem {
 		log.Println(lg("Database", "Downloads", color.HiRedString, "Failed to read database:\t%s", err))
 	}
 	timeT, _ := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", readBack["Time"].(string))
+	hash, _ := readBack["Hash"].(string) // not in older entries
+	canonical, _ := readBack["CanonicalURL"].(string)
+	originMessageID, _ := readBack["OriginMessageID"].(string)
 	return &downloadItem{
 		URL:         readBack["URL"].(string),
 		Time:        timeT,
@@ -151,13 +181,31 @@ func dbFindDownloadByID(id int) *downloadItem {
 		Filename:    readBack["Filename"].(string),
 		ChannelID:   readBack["ChannelID"].(string),
 		UserID:      readBack["UserID"].(string),
+		Hash:        hash,
+
+		CanonicalURL:    canonical,
+		OriginMessageID: originMessageID,
 	}
 }
 
+func dbFindDownloadByHash(hash string) []*downloadItem {
+	query := []interface{}{map[string]interface{}{"eq": hash, "in": []interface{}{"Hash"}}}
+	queryResult := make(map[int]struct{})
+	db.EvalQuery(query, myDB.Use("Downloads"), &queryResult)
+
+	downloads := make([]*downloadItem, 0)
+	for id := range queryResult {
+		downloads = append(downloads, dbFindDownloadByID(id))
+	}
+	return downloads
+}
+
 func dbFindDownloadByURL(inputURL string) []*downloadItem {
-	var query interface{}
-	json.Unmarshal([]byte(fmt.Sprintf(`[{"eq": "%s", "in": ["URL"]}]`, inputURL)), &query)
 	queryResult := make(map[int]struct{})
+	query := []interface{}{map[string]interface{}{"eq": inputURL, "in": []interface{}{"URL"}}}
+	db.EvalQuery(query, myDB.Use("Downloads"), &queryResult)
+	// Other spellings of the same link, Discord's re-signed attachment links included
+	query = []interface{}{map[string]interface{}{"eq": canonicalURL(inputURL), "in": []interface{}{"CanonicalURL"}}}
 	db.EvalQuery(query, myDB.Use("Downloads"), &queryResult)
 
 	downloadedImages := make([]*downloadItem, 0)
@@ -179,6 +227,123 @@ func dbDeleteByChannelID(channelID string) {
 
 //#endregion
 
+//#region Failed Downloads
+
+func dbFailureDoc(failure *downloadFailure) map[string]interface{} {
+	postTime := ""
+	if !failure.PostTime.IsZero() {
+		postTime = failure.PostTime.Format(time.RFC3339)
+	}
+	return map[string]interface{}{
+		"URL":         failure.URL,
+		"Filename":    failure.Filename,
+		"ChannelID":   failure.ChannelID,
+		"MessageID":   failure.MessageID,
+		"GuildID":     failure.GuildID,
+		"UserID":      failure.UserID,
+		"Status":      failure.Status,
+		"StatusCode":  failure.StatusCode,
+		"Error":       failure.Error,
+		"Attempts":    failure.Attempts,
+		"Time":        failure.Time.Format(time.RFC3339),
+		"LastAttempt": failure.LastAttempt.Format(time.RFC3339),
+
+		"AttachmentID":    failure.AttachmentID,
+		"PostTime":        postTime,
+		"DataKeys":        failure.DataKeys,
+		"OriginMessageID": failure.OriginMessageID,
+		"OriginType":      failure.OriginType,
+	}
+}
+
+// dbUpsertFailure records a failure, or bumps the existing record for the same file of the same message.
+func dbUpsertFailure(failure *downloadFailure) error {
+	for _, existing := range dbFindFailuresByURL(failure.URL) {
+		if existing.MessageID == failure.MessageID {
+			failure.ID = existing.ID
+			failure.Time = existing.Time
+			failure.Attempts += existing.Attempts
+			return myDB.Use("Failures").Update(existing.ID, dbFailureDoc(failure))
+		}
+	}
+	id, err := myDB.Use("Failures").Insert(dbFailureDoc(failure))
+	failure.ID = id
+	return err
+}
+
+func dbFailureFromDoc(id int, doc map[string]interface{}) *downloadFailure {
+	str := func(key string) string {
+		value, _ := doc[key].(string)
+		return value
+	}
+	num := func(key string) int {
+		value, _ := doc[key].(float64) // json numbers
+		return int(value)
+	}
+	failure := &downloadFailure{
+		ID:              id,
+		URL:             str("URL"),
+		Filename:        str("Filename"),
+		ChannelID:       str("ChannelID"),
+		MessageID:       str("MessageID"),
+		GuildID:         str("GuildID"),
+		UserID:          str("UserID"),
+		Status:          str("Status"),
+		StatusCode:      num("StatusCode"),
+		Error:           str("Error"),
+		Attempts:        num("Attempts"),
+		AttachmentID:    str("AttachmentID"),
+		OriginMessageID: str("OriginMessageID"),
+		OriginType:      str("OriginType"),
+	}
+	failure.Time, _ = time.Parse(time.RFC3339, str("Time"))
+	failure.LastAttempt, _ = time.Parse(time.RFC3339, str("LastAttempt"))
+	failure.PostTime, _ = time.Parse(time.RFC3339, str("PostTime")) // zero when unknown
+	if keys, ok := doc["DataKeys"].(map[string]interface{}); ok {
+		failure.DataKeys = make(map[string]string, len(keys))
+		for key, value := range keys {
+			failure.DataKeys[key], _ = value.(string)
+		}
+	}
+	return failure
+}
+
+func dbFindFailuresByURL(inputURL string) []*downloadFailure {
+	query := []interface{}{map[string]interface{}{"eq": inputURL, "in": []interface{}{"URL"}}}
+	queryResult := make(map[int]struct{})
+	db.EvalQuery(query, myDB.Use("Failures"), &queryResult)
+
+	failures := make([]*downloadFailure, 0)
+	for id := range queryResult {
+		if doc, err := myDB.Use("Failures").Read(id); err == nil {
+			failures = append(failures, dbFailureFromDoc(id, doc))
+		}
+	}
+	return failures
+}
+
+// dbGetFailures returns the whole queue, oldest first
+func dbGetFailures() []*downloadFailure {
+	failures := make([]*downloadFailure, 0)
+	myDB.Use("Failures").ForEachDoc(func(id int, docContent []byte) (willMoveOn bool) {
+		var doc map[string]interface{}
+		if json.Unmarshal(docContent, &doc) == nil {
+			failures = append(failures, dbFailureFromDoc(id, doc))
+		}
+		return true
+	})
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Time.Before(failures[j].Time)
+	})
+	return failures
+}
+
+func dbDeleteFailure(id int) error {
+	return myDB.Use("Failures").Delete(id)
+}
+
+//#endregion
+
 //#region Statistics
 
 func dbDownloadCount() int {
@@ -410,12 +410,19 @@ func dataKeysDownload(sourceConfig configurationSource, download downloadRequest
 			filesize = humanize.Bytes(uint64(fileinfo.Size()))
 		}
 
+		originType := download.OriginType
+		if originType == "" {
+			originType = "direct"
+		}
+
 		keys := [][]string{
 			{"{{date}}", messageTime.Format(filenameDateFormat)},
 			{"{{file}}", download.Filename},
 			{"{{fileType}}", download.Extension},
 			{"{{fileSize}}", filesize},
 			{"{{attachmentID}}", download.AttachmentID},
+			{"{{originMessageID}}", download.OriginMessageID},
+			{"{{originType}}", originType},
 			{"{{messageID}}", download.Message.ID},
 			{"{{userID}}", userID},
 			{"{{username}}", username},
@@ -438,10 +445,22 @@ func dataKeysDownload(sourceConfig configurationSource, download downloadRequest
 				ret = strings.ReplaceAll(ret, key[0], key[1])
 			}
 		}
+		ret = dataKeys_Extracted(ret, download.DataKeys)
 	}
 	return dataKeys(ret)
 }
 
+// Keys an extractor found for the file, like the subreddit of a Reddit post
+func dataKeys_Extracted(input string, keys map[string]string) string {
+	ret := input
+	if strings.Contains(ret, "{{") && strings.Contains(ret, "}}") {
+		for key, value := range keys {
+			ret = strings.ReplaceAll(ret, "{{"+key+"}}", clearPathIllegalChars(value))
+		}
+	}
+	return ret
+}
+
 func dataKeys_DiscordMessage(input string, m *discordgo.Message) string {
 	ret := input
 	if strings.Contains(ret, "{{") && strings.Contains(ret, "}}") {
@@ -976,11 +995,10 @@ func downloadDiscordEmojis() {
 				countDownloaded := 0
 				countSkipped := 0
 				countFailed := 0
+				var downloads []downloadRequestStruct
 				for _, emoji := range emojis {
-					url := "https://cdn.discordapp.com/emojis/" + emoji.ID
-
-					status, _ := downloadRequestStruct{
-						InputURL:   url,
+					downloads = append(downloads, downloadRequestStruct{
+						InputURL:   "https://cdn.discordapp.com/emojis/" + emoji.ID,
 						Filename:   dataKeysEmoji(*emoji, serverID),
 						Path:       subfolder,
 						Message:    nil,
@@ -989,8 +1007,10 @@ func downloadDiscordEmojis() {
 						HistoryCmd: false,
 						EmojiCmd:   true,
 						StartTime:  time.Now(),
-					}.handleDownload()
-
+					})
+				}
+				for i, result := range queueDownloads(downloads) {
+					url, status := downloads[i].InputURL, result.Status
 					if status.Status == downloadSuccess {
 						countDownloaded++
 					} else if status.Status == downloadSkippedDuplicate {
@@ -1060,11 +1080,10 @@ func downloadDiscordStickers() {
 				countDownloaded := 0
 				countSkipped := 0
 				countFailed := 0
+				var downloads []downloadRequestStruct
 				for _, sticker := range guild.Stickers {
-					url := "https://media.discordapp.net/stickers/" + sticker.ID
-
-					status, _ := downloadRequestStruct{
-						InputURL:   url,
+					downloads = append(downloads, downloadRequestStruct{
+						InputURL:   "https://media.discordapp.net/stickers/" + sticker.ID,
 						Filename:   dataKeysSticker(*sticker),
 						Path:       subfolder,
 						Message:    nil,
@@ -1073,8 +1092,10 @@ func downloadDiscordStickers() {
 						HistoryCmd: false,
 						EmojiCmd:   true,
 						StartTime:  time.Now(),
-					}.handleDownload()
-
+					})
+				}
+				for i, result := range queueDownloads(downloads) {
+					url, status := downloads[i].InputURL, result.Status
 					if status.Status == downloadSuccess {
 						countDownloaded++
 					} else if status.Status == downloadSkippedDuplicate {
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+)
+
+// Unpacking archives from sources with extractArchives set. Each file inside is staged and handled
+// as a download of its own (filters, naming, database) under <archive URL>#<path in archive>.
+
+const (
+	defaultArchiveSubfolder    = "{{archiveName}}"
+	defaultArchiveMaxTotalSize = 2 * humanize.GByte
+	defaultArchiveMaxFiles     = 1000
+	archiveSevenZipTimeout     = 10 * time.Minute
+)
+
+var errArchiveLimits = errors.New("archive is over the unpacking limits")
+
+type archiveLimits struct {
+	MaxTotalSize int64
+	MaxFiles     int
+}
+
+func getArchiveLimits(settings *configurationArchives) archiveLimits {
+	limits := archiveLimits{defaultArchiveMaxTotalSize, defaultArchiveMaxFiles}
+	if size, ok := parseFileSizeFilter(settings.MaxTotalSize); ok {
+		limits.MaxTotalSize = size
+	}
+	if settings.MaxFiles != nil {
+		limits.MaxFiles = *settings.MaxFiles
+	}
+	return limits
+}
+
+// getArchiveFormat says how to unpack a file, empty when it isn't a supported archive. Formats that
+// are zips underneath (docx, epub, apk...) are left alone, as are compressed files that aren't tars.
+func getArchiveFormat(detected fileType, extension string, filename string) string {
+	lowerName := strings.ToLower(filename)
+	switch detected.MIME {
+	case "application/zip":
+		if extension == ".zip" || extension == ".cbz" {
+			return "zip"
+		}
+	case "application/x-tar":
+		return "tar"
+	case "application/gzip":
+		if extension == ".tgz" || strings.HasSuffix(lowerName, ".tar.gz") {
+			return "tar.gz"
+		}
+	case "application/x-bzip2":
+		if extension == ".tbz2" || strings.HasSuffix(lowerName, ".tar.bz2") {
+			return "tar.bz2"
+		}
+	case "application/x-7z-compressed":
+		return "7z"
+	}
+	return ""
+}
+
+// Skips folders, resource forks & anything hidden, and never lets a name climb out of the output folder.
+func archiveMemberName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__MACOSX/") {
+		return ""
+	}
+	base := path.Base(path.Clean("/" + name))
+	if base == "/" || base == "." || strings.HasPrefix(base, ".") {
+		return ""
+	}
+	return base
+}
+
+type archiveMember struct {
+	Name string // path inside the archive
+	Path string // where it was unpacked
+}
+
+// unpackArchive extracts every file into dir, each in a numbered folder of its own so names can repeat.
+func unpackArchive(archivePath string, format string, dir string, limits archiveLimits) ([]archiveMember, error) {
+	var members []archiveMember
+	var total int64
+	// Sizes in headers can lie, so the limit is enforced on what's actually written too
+	extract := func(name string, declaredSize int64, reader io.Reader) error {
+		base := archiveMemberName(name)
+		if base == "" {
+			return nil
+		}
+		if len(members) >= limits.MaxFiles || total+declaredSize > limits.MaxTotalSize {
+			return errArchiveLimits
+		}
+		memberDir := filepath.Join(dir, strconv.Itoa(len(members)))
+		if err := os.Mkdir(memberDir, 0755); err != nil {
+			return err
+		}
+		memberPath := filepath.Join(memberDir, base)
+		file, err := os.Create(memberPath)
+		if err != nil {
+			return err
+		}
+		written, err := io.Copy(file, io.LimitReader(reader, limits.MaxTotalSize-total+1))
+		file.Close()
+		if err != nil {
+			return err
+		}
+		total += written
+		if total > limits.MaxTotalSize {
+			return errArchiveLimits
+		}
+		members = append(members, archiveMember{name, memberPath})
+		return nil
+	}
+
+	switch format {
+	case "zip":
+		archive, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() {
+				continue
+			}
+			reader, err := entry.Open()
+			if err != nil {
+				return members, err
+			}
+			err = extract(entry.Name, int64(entry.UncompressedSize64), reader)
+			reader.Close()
+			if err != nil {
+				return members, err
+			}
+		}
+
+	case "tar", "tar.gz", "tar.bz2":
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		var stream io.Reader = bufio.NewReader(file)
+		switch format {
+		case "tar.gz":
+			gzipReader, err := gzip.NewReader(stream)
+			if err != nil {
+				return nil, err
+			}
+			defer gzipReader.Close()
+			stream = gzipReader
+		case "tar.bz2":
+			stream = bzip2.NewReader(stream)
+		}
+		archive := tar.NewReader(stream)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return members, err
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue // links & devices included
+			}
+			if err := extract(header.Name, header.Size, archive); err != nil {
+				return members, err
+			}
+		}
+
+	case "7z":
+		return unpackSevenZip(archivePath, dir, limits)
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format %s", format)
+	}
+	return members, nil
+}
+
+// 7z goes through 7-Zip, listing first so nothing over the limits is ever unpacked.
+func unpackSevenZip(archivePath string, dir string, limits archiveLimits) ([]archiveMember, error) {
+	sevenZip := getSevenZipPath()
+	if sevenZip == "" {
+		return nil, errors.New("7-Zip not found, set sevenZipPath to unpack 7z archives")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), archiveSevenZipTimeout)
+	defer cancel()
+
+	listing, err := exec.CommandContext(ctx, sevenZip, "l", "-slt", "-ba", archivePath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("7-Zip couldn't list the archive: %s", err)
+	}
+	if files, total := countSevenZipListing(string(listing)); files > limits.MaxFiles || total > limits.MaxTotalSize {
+		return nil, errArchiveLimits
+	}
+
+	unpacked := filepath.Join(dir, "7z")
+	if output, err := exec.CommandContext(ctx, sevenZip, "x", "-y", "-bd", "-o"+unpacked, archivePath).CombinedOutput(); err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return nil, fmt.Errorf("7-Zip failed: %s %s", err, truncateString(lines[len(lines)-1], 200))
+	}
+
+	// Into numbered folders like the other formats, folders having been listed above too
+	var members []archiveMember
+	err = filepath.WalkDir(unpacked, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		name, _ := filepath.Rel(unpacked, filePath)
+		base := archiveMemberName(filepath.ToSlash(name))
+		if base == "" {
+			return nil
+		}
+		if len(members) >= limits.MaxFiles {
+			return errArchiveLimits
+		}
+		memberDir := filepath.Join(dir, strconv.Itoa(len(members)))
+		if err := os.Mkdir(memberDir, 0755); err != nil {
+			return err
+		}
+		memberPath := filepath.Join(memberDir, base)
+		if err := os.Rename(filePath, memberPath); err != nil {
+			return err
+		}
+		members = append(members, archiveMember{filepath.ToSlash(name), memberPath})
+		return nil
+	})
+	os.RemoveAll(unpacked)
+	return members, err
+}
+
+// countSevenZipListing adds up the files & their sizes in a "7z l -slt" listing. Each entry is a block
+// of "Key = value" lines starting at its Path, and folders are listed as entries of their own.
+func countSevenZipListing(listing string) (files int, total int64) {
+	inEntry, folder := false, false
+	endEntry := func() {
+		if inEntry && !folder {
+			files++
+		}
+	}
+	for _, line := range strings.Split(listing, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " = ")
+		switch key {
+		case "Path":
+			endEntry()
+			inEntry, folder = true, false
+		case "Folder":
+			folder = folder || value == "+"
+		case "Attributes":
+			folder = folder || strings.HasPrefix(value, "D")
+		case "Size":
+			size, _ := strconv.ParseInt(value, 10, 64)
+			total += size
+		}
+	}
+	endEntry()
+	return files, total
+}
+
+// Settings first, then the usual names on PATH.
+func getSevenZipPath() string {
+	if config.SevenZipPath != "" {
+		return config.SevenZipPath
+	}
+	for _, name := range []string{"7z", "7zz", "7za"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// expandArchive unpacks a downloaded archive and runs each file through the download pipeline,
+// saving them under the archive's subfolder within destination.
+func (download downloadRequestStruct) expandArchive(archivePath string, format string, archiveFilename string,
+	sourceURL string, destination string, settings *configurationArchives, sourceConfig configurationSource) {
+	dir, err := os.MkdirTemp("", "ddg-archive-")
+	if err != nil {
+		log.Println(lg("Download", "Archive", color.HiRedString, "Failed to create temporary folder: %s", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	members, err := unpackArchive(archivePath, format, dir, getArchiveLimits(settings))
+	if err != nil {
+		log.Println(lg("Download", "Archive", color.HiRedString,
+			"Failed to unpack %s \"%s\": %s", format, download.Filename, err))
+		return
+	}
+
+	archiveName := strings.TrimSuffix(archiveFilename, filepath.Ext(archiveFilename))
+	archiveName = strings.TrimSuffix(archiveName, ".tar")
+	keys := map[string]string{"archiveName": archiveName}
+	for key, value := range download.DataKeys {
+		keys[key] = value
+	}
+
+	subfolder := defaultArchiveSubfolder
+	if settings.Subfolder != nil {
+		subfolder = *settings.Subfolder
+	}
+	if subfolder != "" {
+		subfolder = dataKeys_Extracted(subfolder, keys)
+		subfolder = dataKeys_DiscordMessage(subfolder, download.Message)
+		subfolder = clearSourceField(subfolder, sourceConfig)
+		destination += subfolder + string(os.PathSeparator)
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			log.Println(lg("Download", "Archive", color.HiRedString,
+				"Error while creating subfolder \"%s\": %s", destination, err))
+			return
+		}
+	}
+
+	saved := 0
+	for _, member := range members {
+		memberURL := sourceURL + "#" + member.Name
+		if len(pruneCompletedLinks([]*fileItem{{Link: memberURL}}, download.Message)) == 0 {
+			continue
+		}
+		memberDownload := download
+		memberDownload.InputURL = stageFile(member.Path, memberURL)
+		memberDownload.Filename = filepath.Base(member.Path)
+		memberDownload.Extension = ""
+		memberDownload.Path = destination
+		memberDownload.DataKeys = keys
+		memberDownload.ArchiveMember = true
+		memberDownload.StartTime = time.Now()
+		if status, _ := memberDownload.handleDownload(); status.Status == downloadSuccess {
+			saved++
+		}
+	}
+	log.Println(lg("Download", "Archive", color.HiGreenString,
+		"Saved %d of %d files unpacked from \"%s\"", saved, len(members), download.Filename))
+}
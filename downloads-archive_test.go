@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestArchiveMemberName(t *testing.T) {
+	tests := map[string]string{
+		"photo.jpg":                  "photo.jpg",
+		"album/photo.jpg":            "photo.jpg",
+		"album\\nested\\photo.jpg":   "photo.jpg",
+		"../../../etc/passwd":        "passwd",
+		"/absolute/photo.jpg":        "photo.jpg",
+		"album/":                     "",
+		"__MACOSX/album/._photo.jpg": "",
+		".hidden":                    "",
+		"album/.DS_Store":            "",
+		"..":                         "",
+		"":                           "",
+	}
+	for name, want := range tests {
+		if got := archiveMemberName(name); got != want {
+			t.Errorf("archiveMemberName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGetArchiveLimits(t *testing.T) {
+	limits := getArchiveLimits(&configurationArchives{})
+	if limits.MaxTotalSize != defaultArchiveMaxTotalSize || limits.MaxFiles != defaultArchiveMaxFiles {
+		t.Errorf("defaults = %+v", limits)
+	}
+	size, files := "10MB", 5
+	limits = getArchiveLimits(&configurationArchives{MaxTotalSize: &size, MaxFiles: &files})
+	if limits.MaxTotalSize != 10_000_000 || limits.MaxFiles != 5 {
+		t.Errorf("configured = %+v", limits)
+	}
+}
+
+func writeTestArchive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(archivePath, testZip(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+func TestUnpackArchiveLimits(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 4; i++ {
+		files[fmt.Sprintf("file%d.txt", i)] = "0123456789"
+	}
+	archivePath := writeTestArchive(t, files)
+
+	members, err := unpackArchive(archivePath, "zip", t.TempDir(), archiveLimits{MaxTotalSize: 1000, MaxFiles: 4})
+	if err != nil || len(members) != 4 {
+		t.Fatalf("within limits: %d members, %v", len(members), err)
+	}
+	if _, err := unpackArchive(archivePath, "zip", t.TempDir(), archiveLimits{MaxTotalSize: 1000, MaxFiles: 3}); err != errArchiveLimits {
+		t.Errorf("over file limit: %v", err)
+	}
+	if _, err := unpackArchive(archivePath, "zip", t.TempDir(), archiveLimits{MaxTotalSize: 35, MaxFiles: 10}); err != errArchiveLimits {
+		t.Errorf("over size limit: %v", err)
+	}
+}
+
+func TestUnpackArchiveSkipsFoldersAndHidden(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, name := range []string{"album/", "album/photo.jpg", "album/photo.jpg", "__MACOSX/album/._photo.jpg", ".hidden"} {
+		file, _ := writer.Create(name)
+		if name[len(name)-1] != '/' {
+			file.Write([]byte("data"))
+		}
+	}
+	writer.Close()
+	archivePath := filepath.Join(t.TempDir(), "test.zip")
+	os.WriteFile(archivePath, buffer.Bytes(), 0644)
+
+	members, err := unpackArchive(archivePath, "zip", t.TempDir(), archiveLimits{MaxTotalSize: 1000, MaxFiles: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Repeated names each get a folder of their own
+	if len(members) != 2 || filepath.Dir(members[0].Path) == filepath.Dir(members[1].Path) {
+		t.Errorf("members = %+v", members)
+	}
+}
+
+// Files with a NUL type flag are read as regular ones, written by hand since Go's writer won't produce them.
+func TestUnpackArchiveTarNulTypeflag(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	content := []byte("nul type flag")
+	writer.WriteHeader(&tar.Header{Name: "file.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	writer.Write(content)
+	writer.Close()
+
+	archive := buffer.Bytes()
+	archive[156] = 0
+	checksum := 0
+	for i, b := range archive[:512] {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		checksum += int(b)
+	}
+	copy(archive[148:156], fmt.Sprintf("%06s\x00 ", strconv.FormatInt(int64(checksum), 8)))
+
+	archivePath := filepath.Join(t.TempDir(), "test.tar")
+	os.WriteFile(archivePath, archive, 0644)
+	members, err := unpackArchive(archivePath, "tar", t.TempDir(), archiveLimits{MaxTotalSize: 1000, MaxFiles: 10})
+	if err != nil || len(members) != 1 {
+		t.Fatalf("%d members, %v", len(members), err)
+	}
+	if data, _ := os.ReadFile(members[0].Path); !bytes.Equal(data, content) {
+		t.Errorf("unpacked %q", data)
+	}
+}
+
+func TestCountSevenZipListing(t *testing.T) {
+	listing := `Path = album
+Size = 0
+Packed Size = 0
+Modified = 2024-01-01 00:00:00
+Attributes = D_ drwxr-xr-x
+Folder = +
+
+Path = album/empty.txt
+Size = 0
+Packed Size = 0
+Attributes = A_ -rw-r--r--
+Folder = -
+
+Path = album/photo.jpg
+Size = 2048
+Packed Size = 1900
+Attributes = A_ -rw-r--r--
+
+Path = windows
+Size = 0
+Attributes = D....
+`
+	files, total := countSevenZipListing(listing)
+	if files != 2 || total != 2048 {
+		t.Errorf("countSevenZipListing = %d files, %d bytes, want 2 & 2048", files, total)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fatih/color"
+)
+
+// Downloads that were given up on are kept in the "Failures" collection so they can be retried
+// later, either through the failures command or the optional sweeper.
+
+type downloadFailure struct {
+	ID          int
+	URL         string
+	Filename    string
+	ChannelID   string
+	MessageID   string
+	GuildID     string
+	UserID      string
+	Status      string // label, the enum values shift as statuses get added
+	StatusCode  int
+	Error       string
+	Attempts    int
+	Time        time.Time // first failure
+	LastAttempt time.Time
+	// What the extractors knew about the file, so a retry names & dates it the same
+	AttachmentID    string
+	PostTime        time.Time
+	DataKeys        map[string]string
+	OriginMessageID string
+	OriginType      string
+}
+
+func recordDownloadFailure(download downloadRequestStruct, status downloadStatusStruct, attempts int) {
+	if download.EmojiCmd || download.Message == nil || isStagedLink(download.InputURL) { // staged files are gone by now
+		return
+	}
+	failure := &downloadFailure{
+		URL:             download.InputURL,
+		Filename:        download.Filename,
+		ChannelID:       download.Message.ChannelID,
+		MessageID:       download.Message.ID,
+		GuildID:         download.Message.GuildID,
+		Status:          getDownloadStatus(status.Status),
+		StatusCode:      status.StatusCode,
+		Attempts:        attempts,
+		Time:            time.Now(),
+		LastAttempt:     time.Now(),
+		AttachmentID:    download.AttachmentID,
+		PostTime:        download.PostTime,
+		DataKeys:        download.DataKeys,
+		OriginMessageID: download.OriginMessageID,
+		OriginType:      download.OriginType,
+	}
+	if download.Message.Author != nil {
+		failure.UserID = download.Message.Author.ID
+	}
+	if status.Error != nil {
+		failure.Error = status.Error.Error()
+	}
+	if err := dbUpsertFailure(failure); err != nil {
+		log.Println(lg("Database", "Failures", color.HiRedString,
+			"Error saving failed download %s: %s", download.InputURL, err))
+	}
+}
+
+// clearDownloadFailure drops queued failures for a file that has since been downloaded.
+func clearDownloadFailure(download downloadRequestStruct) {
+	if download.EmojiCmd || download.Message == nil {
+		return
+	}
+	for _, failure := range dbFindFailuresByURL(download.InputURL) {
+		if failure.MessageID == download.Message.ID {
+			dbDeleteFailure(failure.ID)
+		}
+	}
+}
+
+// retryDownloadFailures puts failures back through the download queue, returning how many succeeded.
+// Whatever fails again is updated in place by handleDownload.
+func retryDownloadFailures(failures []*downloadFailure) int {
+	var downloads []downloadRequestStruct
+	for _, failure := range failures {
+		if _, err := getChannel(failure.ChannelID); err != nil {
+			log.Println(lg("Download", "Failures", color.RedString,
+				"Can't retry %s, channel %s is unavailable: %s", failure.URL, failure.ChannelID, err))
+			continue
+		}
+		message, err := bot.ChannelMessage(failure.ChannelID, failure.MessageID)
+		if err != nil { // deleted or unreadable, enough to route the file to its source
+			message = &discordgo.Message{
+				ID:        failure.MessageID,
+				ChannelID: failure.ChannelID,
+				GuildID:   failure.GuildID,
+				Author:    &discordgo.User{ID: failure.UserID},
+				Timestamp: failure.Time,
+			}
+		}
+		sourceConfig := getSource(message)
+		if sourceConfig == emptySourceConfig {
+			log.Println(lg("Download", "Failures", color.RedString,
+				"Can't retry %s, channel %s is no longer a source", failure.URL, failure.ChannelID))
+			continue
+		}
+		downloads = append(downloads, downloadRequestStruct{
+			InputURL:        failure.URL,
+			Filename:        failure.Filename,
+			Path:            sourceConfig.Destination,
+			Message:         message,
+			FileTime:        message.Timestamp,
+			PostTime:        failure.PostTime,
+			HistoryCmd:      true, // runs in the background, no reactions to live traffic
+			AttachmentID:    failure.AttachmentID,
+			DataKeys:        failure.DataKeys,
+			OriginMessageID: failure.OriginMessageID,
+			OriginType:      failure.OriginType,
+		})
+	}
+
+	succeeded := 0
+	for _, result := range queueDownloads(downloads) {
+		if result.Status.Status == downloadSuccess {
+			succeeded++
+		}
+	}
+	return succeeded
+}
+
+func sweepDownloadFailures() {
+	var due []*downloadFailure
+	for _, failure := range dbGetFailures() {
+		if time.Since(failure.LastAttempt) >= time.Duration(config.FailedDownloadSweepAge)*time.Minute {
+			due = append(due, failure)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	log.Println(lg("Download", "Failures", color.CyanString,
+		"Retrying %d failed download%s...", len(due), pluralS(len(due))))
+	succeeded := retryDownloadFailures(due)
+	log.Println(lg("Download", "Failures", color.HiCyanString,
+		"Recovered %d of %d failed download%s", succeeded, len(due), pluralS(len(due))))
+}
+
+// Newest first, as many as fit in a plain message since selfbots can't send embeds.
+func formatDownloadFailureList(failures []*downloadFailure) string {
+	const listMax, listLength = 15, 1500
+	var lines []string
+	length := 0
+	for i := len(failures) - 1; i >= 0 && len(lines) < listMax; i-- {
+		line := formatDownloadFailure(failures[i])
+		if length += len(line) + 2; length > listLength && len(lines) > 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	content := fmt.Sprintf("**%d failed download%s queued**\n\n%s",
+		len(failures), pluralS(len(failures)), strings.Join(lines, "\n\n"))
+	if len(failures) > len(lines) {
+		content += fmt.Sprintf("\n\n...and %d more", len(failures)-len(lines))
+	}
+	return content
+}
+
+// Listed without the query, signed Discord links alone would use up most of a message.
+func formatDownloadFailure(failure *downloadFailure) string {
+	status := failure.Status
+	if failure.StatusCode != 0 {
+		status += fmt.Sprintf(" (HTTP %d)", failure.StatusCode)
+	}
+	link := failure.URL
+	if index := strings.IndexAny(link, "?#"); index != -1 {
+		link = link[:index]
+	}
+	return fmt.Sprintf("`%d` <%s>\n<#%s> — %s, %d attempt%s, last %s ago",
+		failure.ID, truncateString(link, 200), failure.ChannelID, status,
+		failure.Attempts, pluralS(failure.Attempts), timeSinceShort(failure.LastAttempt))
+}
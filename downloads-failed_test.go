@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFailureKeepsExtractorDetails(t *testing.T) {
+	source := useTestSource(setupTestDownloads(t))
+	download := testDownloadRequest("https://example.com/post/1.jpg", source)
+	download.Filename = "1.jpg"
+	download.AttachmentID = "300"
+	download.PostTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	download.DataKeys = map[string]string{"author": "someone", "postID": "1"}
+	download.OriginMessageID = "400"
+	download.OriginType = "forward"
+
+	recordDownloadFailure(download, downloadStatusStruct{Status: downloadFailedCode, StatusCode: 503, Error: errors.New("unavailable")}, 3)
+	failures := dbGetFailures()
+	if len(failures) != 1 {
+		t.Fatalf("%d failures recorded, want 1", len(failures))
+	}
+	failure := failures[0]
+	if failure.AttachmentID != "300" || failure.OriginMessageID != "400" || failure.OriginType != "forward" {
+		t.Errorf("failure = %+v", failure)
+	}
+	if !failure.PostTime.Equal(download.PostTime) {
+		t.Errorf("post time = %s, want %s", failure.PostTime, download.PostTime)
+	}
+	if !reflect.DeepEqual(failure.DataKeys, download.DataKeys) {
+		t.Errorf("data keys = %v, want %v", failure.DataKeys, download.DataKeys)
+	}
+
+	// Without extractor details nothing is made up
+	plain := testDownloadRequest("https://example.com/plain.jpg", source)
+	recordDownloadFailure(plain, downloadStatusStruct{Status: downloadFailedCode}, 1)
+	for _, failure := range dbFindFailuresByURL(plain.InputURL) {
+		if !failure.PostTime.IsZero() || len(failure.DataKeys) != 0 {
+			t.Errorf("plain failure = %+v", failure)
+		}
+	}
+}
+
+func TestDownloadFailureListFitsMessage(t *testing.T) {
+	var failures []*downloadFailure
+	for i := 0; i < 40; i++ {
+		failures = append(failures, &downloadFailure{
+			ID:          i,
+			URL:         "https://cdn.discordapp.com/attachments/100/200/" + strings.Repeat("long_name", 10) + ".png?ex=67&is=67&hm=" + strings.Repeat("f", 64),
+			ChannelID:   "100",
+			Status:      "Download Failed",
+			StatusCode:  503,
+			Attempts:    3,
+			LastAttempt: time.Now(),
+		})
+	}
+	content := formatDownloadFailureList(failures)
+	if length := len([]rune(content)); length > 2000-100 {
+		t.Errorf("list is %d characters, too long for a message", length)
+	}
+	if strings.Contains(content, "hm=") {
+		t.Error("link queries listed")
+	}
+	if !strings.Contains(content, "`39`") || !strings.Contains(content, "more") {
+		t.Errorf("newest first and a count of the rest expected:\n%s", content)
+	}
+}
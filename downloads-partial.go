@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Partial downloads live in the cache folder named after the source URL, so an interrupted transfer
+// can be picked back up by a later attempt or after a restart. The sidecar keeps the validators
+// needed to make sure the remote file didn't change meanwhile. Whatever's never picked back up is
+// swept at startup once it's old enough.
+
+type partialDownload struct {
+	Path         string `json:"-"`
+	Size         int64  `json:"-"`
+	SHA256       string `json:"-"` // hex digest of the whole file, set once write completes
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+const (
+	partialDownloadHeadSize = 512
+	partialDownloadExpiry   = 7 * 24 * time.Hour
+)
+
+var (
+	partialDownloadsMutex  sync.Mutex
+	partialDownloadsCond   = sync.NewCond(&partialDownloadsMutex)
+	partialDownloadsActive = map[string]bool{}
+)
+
+// claimPartialDownload loads the partial file for inputURL, waiting first if another worker
+// is busy with the same one. It has to be released once the attempt is over.
+func claimPartialDownload(inputURL string) *partialDownload {
+	hash := sha1.Sum([]byte(inputURL))
+	partial := &partialDownload{
+		Path: filepath.Join(pathCachePartials, hex.EncodeToString(hash[:10])+".part"),
+		URL:  inputURL,
+	}
+	os.MkdirAll(pathCachePartials, 0755)
+
+	partialDownloadsMutex.Lock()
+	for partialDownloadsActive[partial.Path] {
+		partialDownloadsCond.Wait()
+	}
+	partialDownloadsActive[partial.Path] = true
+	partialDownloadsMutex.Unlock()
+
+	if stat, err := os.Stat(partial.Path); err == nil {
+		partial.Size = stat.Size()
+	}
+	if partial.Size > 0 {
+		if sidecar, err := os.ReadFile(partial.sidecarPath()); err == nil {
+			var saved partialDownload
+			if err = json.Unmarshal(sidecar, &saved); err == nil && saved.URL == inputURL {
+				partial.ETag = saved.ETag
+				partial.LastModified = saved.LastModified
+			}
+		}
+	}
+	return partial
+}
+
+func (partial *partialDownload) release() {
+	partialDownloadsMutex.Lock()
+	delete(partialDownloadsActive, partial.Path)
+	partialDownloadsCond.Broadcast()
+	partialDownloadsMutex.Unlock()
+}
+
+func (partial *partialDownload) sidecarPath() string {
+	return partial.Path + ".json"
+}
+
+// validator returns the value for If-Range, weak ETags aren't allowed there.
+func (partial *partialDownload) validator() string {
+	if partial.ETag != "" && !strings.HasPrefix(partial.ETag, "W/") {
+		return partial.ETag
+	}
+	return partial.LastModified
+}
+
+// resumable is true when enough was saved to sniff from disk and the server gave us something to validate against.
+func (partial *partialDownload) resumable() bool {
+	return partial.Size >= partialDownloadHeadSize && partial.validator() != ""
+}
+
+func (partial *partialDownload) setRangeHeaders(request *http.Request) {
+	if partial.resumable() {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", partial.Size))
+		request.Header.Set("If-Range", partial.validator())
+	}
+}
+
+// acceptsResume checks the server actually continued from where the partial file ends.
+// Anything else (200 after a changed validator, a different offset) means starting over.
+func (partial *partialDownload) acceptsResume(response *http.Response) bool {
+	if !partial.resumable() || response.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	var start, end int64
+	if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); err != nil {
+		return false
+	}
+	return start == partial.Size
+}
+
+// reset starts the partial over from zero with the validators of a fresh response.
+func (partial *partialDownload) reset(response *http.Response) {
+	partial.Size = 0
+	partial.ETag = response.Header.Get("ETag")
+	partial.LastModified = response.Header.Get("Last-Modified")
+	os.Remove(partial.Path)
+	if sidecar, err := json.Marshal(partial); err == nil {
+		os.WriteFile(partial.sidecarPath(), sidecar, 0644)
+	}
+}
+
+func (partial *partialDownload) head() ([]byte, error) {
+	file, err := os.Open(partial.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	head := make([]byte, partialDownloadHeadSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// write streams body onto the partial file and syncs it to disk. What was written is left in
+// place on failure, the caller decides whether it's worth keeping for the next attempt.
+func (partial *partialDownload) write(body io.Reader) downloadStatusStruct {
+	file, err := os.OpenFile(partial.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return mDownloadStatus(downloadFailedWritingFile, err)
+	}
+
+	// Hash as we go, catching up on whatever an earlier attempt already wrote
+	hasher := sha256.New()
+	if partial.Size > 0 {
+		existing, err := os.Open(partial.Path)
+		if err != nil {
+			file.Close()
+			return mDownloadStatus(downloadFailedWritingFile, err)
+		}
+		_, err = io.Copy(hasher, existing)
+		existing.Close()
+		if err != nil {
+			file.Close()
+			return mDownloadStatus(downloadFailedWritingFile, err)
+		}
+	}
+
+	// Keep read & write errors apart, a dropped connection is not a disk problem.
+	reader := &errTrackingReader{r: body}
+	written, err := io.Copy(io.MultiWriter(file, hasher), reader)
+	partial.Size += written
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if reader.err != nil {
+			return mDownloadStatus(downloadFailedReadResponse, reader.err)
+		}
+		return mDownloadStatus(downloadFailedWritingFile, err)
+	}
+	partial.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	return mDownloadStatus(downloadSuccess)
+}
+
+func (partial *partialDownload) remove() {
+	os.Remove(partial.Path)
+	os.Remove(partial.sidecarPath())
+}
+
+// moveTo puts the finished file at path, copying it over when that's on another drive than the cache.
+func (partial *partialDownload) moveTo(path string) error {
+	if err := os.Rename(partial.Path, path); err == nil {
+		return nil
+	}
+	source, err := os.Open(partial.Path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	destination, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(destination, source); err == nil {
+		err = destination.Sync()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	source.Close()
+	return os.Remove(partial.Path)
+}
+
+// sweepPartialDownloads removes partial files that haven't been touched in a while, along with their sidecars.
+func sweepPartialDownloads() {
+	entries, err := os.ReadDir(pathCachePartials)
+	if err != nil {
+		return
+	}
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || time.Since(info.ModTime()) < partialDownloadExpiry {
+			continue
+		}
+		path := filepath.Join(pathCachePartials, entry.Name())
+		if strings.HasSuffix(path, ".json") {
+			if _, err := os.Stat(strings.TrimSuffix(path, ".json")); err == nil {
+				continue // goes with its partial
+			}
+		}
+		if os.Remove(path) == nil {
+			removed++
+		}
+		os.Remove(path + ".json")
+	}
+	if removed > 0 && config.Verbose {
+		log.Println(lg("Verbose", "Download", color.HiBlueString,
+			"Removed %d abandoned partial download%s", removed, pluralS(removed)))
+	}
+}
+
+type errTrackingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (reader *errTrackingReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartialDownloadResume(t *testing.T) {
+	pathCachePartials = t.TempDir()
+	partial := claimPartialDownload("https://example.com/video.mp4")
+	if filepath.Dir(partial.Path) != pathCachePartials {
+		t.Errorf("partial at %s, not in the cache", partial.Path)
+	}
+	partial.reset(&http.Response{Header: http.Header{"Etag": {`"v1"`}}})
+	partial.write(strings.NewReader(strings.Repeat("a", partialDownloadHeadSize)))
+	partial.release()
+
+	partial = claimPartialDownload("https://example.com/video.mp4")
+	defer partial.release()
+	if !partial.resumable() || partial.Size != partialDownloadHeadSize || partial.ETag != `"v1"` {
+		t.Fatalf("partial = %+v", partial)
+	}
+	request, _ := http.NewRequest("GET", "https://example.com/video.mp4", nil)
+	partial.setRangeHeaders(request)
+	if request.Header.Get("Range") != "bytes=512-" || request.Header.Get("If-Range") != `"v1"` {
+		t.Errorf("headers = %v", request.Header)
+	}
+
+	destination := filepath.Join(t.TempDir(), "video.mp4")
+	if err := partial.moveTo(destination); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(destination); err != nil || info.Size() != partialDownloadHeadSize {
+		t.Errorf("moved file: %v, %v", info, err)
+	}
+}
+
+func TestSweepPartialDownloads(t *testing.T) {
+	config = defaultConfiguration()
+	pathCachePartials = t.TempDir()
+	old := time.Now().Add(-partialDownloadExpiry - time.Hour)
+	write := func(name string, modified time.Time) string {
+		path := filepath.Join(pathCachePartials, name)
+		os.WriteFile(path, []byte("data"), 0644)
+		os.Chtimes(path, modified, modified)
+		return path
+	}
+	abandoned := write("abandoned.part", old)
+	abandonedSidecar := write("abandoned.part.json", old)
+	orphanSidecar := write("orphan.part.json", old)
+	recent := write("recent.part", time.Now())
+	recentSidecar := write("recent.part.json", old) // written when the partial started, appended since
+
+	sweepPartialDownloads()
+	for _, path := range []string{abandoned, abandonedSidecar, orphanSidecar} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s wasn't swept", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{recent, recentSidecar} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was swept", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// All downloads go through a fixed pool of workers, so a busy channel or a large history job
+// can't open an unbounded number of connections. Live messages are always picked up first,
+// history only gets a worker when nothing live is waiting. Workers never wait around themselves,
+// a retry goes back in line once its backoff is up and deferred files queue what they turn into.
+
+type downloadJob struct {
+	download downloadRequestStruct
+	result   chan downloadJobResult
+	attempts []downloadStatusStruct // failed so far
+}
+
+type downloadJobResult struct {
+	Status   downloadStatusStruct
+	Filesize int64
+}
+
+var (
+	downloadQueueLive    = make(chan *downloadJob)
+	downloadQueueHistory = make(chan *downloadJob)
+	downloadWorkersOnce  sync.Once
+
+	// Guards the catalog & its ID counter, duplo isn't safe for concurrent use.
+	duploMutex sync.Mutex
+	// Held from checking a destination is free until the file is moved there.
+	downloadPathMutex sync.Mutex
+)
+
+func startDownloadWorkers() {
+	downloadWorkersOnce.Do(func() {
+		for i := 0; i < config.DownloadWorkers; i++ {
+			go downloadWorker()
+		}
+		if config.Verbose {
+			log.Println(lg("Verbose", "Download", color.HiBlueString,
+				"Started %d download worker%s", config.DownloadWorkers, pluralS(config.DownloadWorkers)))
+		}
+	})
+}
+
+func downloadWorker() {
+	for {
+		var job *downloadJob
+		select {
+		case job = <-downloadQueueLive:
+		default:
+			select {
+			case job = <-downloadQueueLive:
+			case job = <-downloadQueueHistory:
+			}
+		}
+		job.run()
+	}
+}
+
+func (job *downloadJob) enqueue() {
+	if job.download.HistoryCmd {
+		downloadQueueHistory <- job
+	} else {
+		downloadQueueLive <- job
+	}
+}
+
+// run makes one attempt, answering the job unless it's put back in line for another.
+func (job *downloadJob) run() {
+	if job.download.Resolve != nil {
+		job.resolve()
+		return
+	}
+
+	sourceConfig := emptySourceConfig
+	if !job.download.EmojiCmd {
+		sourceConfig = getSource(job.download.Message)
+	}
+	policy := getRetryPolicy(sourceConfig)
+
+	status, filesize := job.download.tryDownload()
+	if status.Status >= downloadFailed {
+		job.attempts = append(job.attempts, status)
+		if len(job.attempts) < policy.MaxAttempts && policy.shouldRetry(status) {
+			time.AfterFunc(policy.delay(len(job.attempts)), job.enqueue)
+			return
+		}
+	}
+	job.download.finishDownload(status, job.attempts)
+	job.result <- downloadJobResult{status, filesize}
+}
+
+// resolve fetches what a deferred file turns into and queues each of those, the job succeeding
+// when any of them does.
+func (job *downloadJob) resolve() {
+	items, err := job.download.Resolve()
+	if err != nil {
+		log.Println(lg("Download", "", color.RedString, "Failed to fetch files from %s: %s", job.download.InputURL, err))
+		job.result <- downloadJobResult{mDownloadStatus(downloadFailed, err), 0}
+		return
+	}
+	go func() {
+		result := downloadJobResult{Status: mDownloadStatus(downloadSkipped)}
+		for _, itemResult := range queueDownloads(job.download.deferredDownloads(items)) {
+			if itemResult.Status.Status == downloadSuccess {
+				result.Filesize += itemResult.Filesize
+			}
+			if result.Status.Status != downloadSuccess {
+				result.Status = itemResult.Status
+			}
+		}
+		job.result <- result
+	}()
+}
+
+// queueDownloads hands every request to the worker pool and waits for all of them,
+// results come back in the same order as the requests.
+func queueDownloads(downloads []downloadRequestStruct) []downloadJobResult {
+	jobs := make([]*downloadJob, len(downloads))
+	for i, download := range downloads {
+		jobs[i] = &downloadJob{
+			download: download,
+			result:   make(chan downloadJobResult, 1),
+		}
+		jobs[i].download.StartTime = time.Now() // don't count time spent waiting in line
+		go jobs[i].enqueue()
+	}
+
+	results := make([]downloadJobResult, len(jobs))
+	for i, job := range jobs {
+		results[i] = <-job.result
+	}
+	return results
+}
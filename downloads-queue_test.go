@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRetryFreesWorker(t *testing.T) {
+	source := setupTestDownloads(t)
+	text := true
+	attempts, backoff := 2, 0.5
+	source.SaveTextFiles = &text
+	source.DownloadRetryPolicy = &configurationRetryPolicy{MaxAttempts: &attempts, Backoff: &backoff}
+	source = useTestSource(source)
+
+	var mutex sync.Mutex
+	var served []string
+	failed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path == "/flaky.txt" && served == nil {
+			served = []string{}
+			close(failed)
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		served = append(served, r.URL.Path)
+		w.Write([]byte("text for " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	flaky := testDownloadRequest(server.URL+"/flaky.txt", source)
+	steady := testDownloadRequest(server.URL+"/steady.txt", source)
+	steady.Message.ID = "201"
+	config.DownloadWorkers = 1
+	startDownloadWorkers()
+	flakyResult := make(chan downloadJobResult)
+	go func() { flakyResult <- queueDownloads([]downloadRequestStruct{flaky})[0] }()
+	<-failed
+	for _, result := range append(queueDownloads([]downloadRequestStruct{steady}), <-flakyResult) {
+		if result.Status.Status != downloadSuccess {
+			t.Errorf("status = %s (%v)", getDownloadStatus(result.Status.Status), result.Status.Error)
+		}
+	}
+	if len(served) != 2 || served[0] != "/steady.txt" {
+		t.Errorf("served %v, the steady file should've gone while the flaky one backed off", served)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type retryPolicy struct {
+	MaxAttempts     int
+	Timeout         time.Duration
+	Backoff         time.Duration
+	BackoffMax      time.Duration
+	Jitter          float64
+	RetryCodes      []string
+	NeverRetryCodes []string
+}
+
+// Gone for good or not ours to see, another attempt won't change that.
+var defaultNeverRetryCodes = []string{"403", "404", "410"}
+
+// getRetryPolicy resolves the policy for a source, each setting falling back to the global one.
+func getRetryPolicy(sourceConfig configurationSource) retryPolicy {
+	policy := retryPolicy{
+		MaxAttempts:     config.DownloadRetryMax,
+		Timeout:         time.Duration(config.DownloadTimeout) * time.Second,
+		Backoff:         5 * time.Second,
+		BackoffMax:      2 * time.Minute,
+		Jitter:          0.2,
+		NeverRetryCodes: defaultNeverRetryCodes,
+	}
+	for _, override := range []*configurationRetryPolicy{config.DownloadRetryPolicy, sourceConfig.DownloadRetryPolicy} {
+		if override == nil {
+			continue
+		}
+		if override.MaxAttempts != nil && *override.MaxAttempts > 0 {
+			policy.MaxAttempts = *override.MaxAttempts
+		}
+		if override.Timeout != nil && *override.Timeout > 0 {
+			policy.Timeout = time.Duration(*override.Timeout) * time.Second
+		}
+		if override.Backoff != nil && *override.Backoff >= 0 {
+			policy.Backoff = time.Duration(*override.Backoff * float64(time.Second))
+		}
+		if override.BackoffMax != nil && *override.BackoffMax >= 0 {
+			policy.BackoffMax = time.Duration(*override.BackoffMax * float64(time.Second))
+		}
+		if override.Jitter != nil {
+			policy.Jitter = math.Min(1, math.Max(0, *override.Jitter))
+		}
+		if override.RetryCodes != nil {
+			policy.RetryCodes = *override.RetryCodes
+		}
+		if override.NeverRetryCodes != nil {
+			policy.NeverRetryCodes = *override.NeverRetryCodes
+		}
+	}
+	return policy
+}
+
+// matchStatusCode checks code against rules like "429" or "5xx"
+func matchStatusCode(code int, rules []string) bool {
+	codeStr := strconv.Itoa(code)
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if rule == codeStr {
+			return true
+		}
+		if len(rule) == 3 && strings.HasSuffix(rule, "xx") && rule[0] == codeStr[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy retryPolicy) shouldRetry(status downloadStatusStruct) bool {
+	if status.Status < downloadFailed {
+		return false
+	}
+	switch status.Status {
+	case downloadFailedInvalidSource, downloadFailedInvalidPath:
+		return false
+	}
+	if status.StatusCode == 0 {
+		return true
+	}
+	if matchStatusCode(status.StatusCode, policy.NeverRetryCodes) {
+		return false
+	}
+	if len(policy.RetryCodes) > 0 {
+		return matchStatusCode(status.StatusCode, policy.RetryCodes)
+	}
+	return true
+}
+
+// delay before the given retry (1 being the first), doubling each time with some jitter either way.
+func (policy retryPolicy) delay(retry int) time.Duration {
+	delay := float64(policy.Backoff) * math.Pow(2, float64(retry-1))
+	if policy.BackoffMax > 0 {
+		delay = math.Min(delay, float64(policy.BackoffMax))
+	}
+	delay += delay * policy.Jitter * (rand.Float64()*2 - 1)
+	return time.Duration(delay)
+}
+
+// Lists what each attempt ran into, for failure notices.
+func formatDownloadAttempts(attempts []downloadStatusStruct) string {
+	var lines []string
+	for i, attempt := range attempts {
+		line := fmt.Sprintf("#%d: %s", i+1, getDownloadStatus(attempt.Status))
+		if attempt.StatusCode != 0 {
+			line += fmt.Sprintf(" (HTTP %d)", attempt.StatusCode)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
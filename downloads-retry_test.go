@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetRetryPolicy(t *testing.T) {
+	config = defaultConfiguration()
+	config.DownloadRetryMax = 3
+	config.DownloadTimeout = 60
+
+	policy := getRetryPolicy(configurationSource{})
+	if policy.MaxAttempts != 3 || policy.Timeout != time.Minute {
+		t.Errorf("global settings not used: %+v", policy)
+	}
+
+	globalBackoff, globalAttempts := 2.0, 5
+	config.DownloadRetryPolicy = &configurationRetryPolicy{Backoff: &globalBackoff, MaxAttempts: &globalAttempts}
+	sourceAttempts, sourceJitter := 8, 4.0
+	retryCodes := []string{"429", "5xx"}
+	source := configurationSource{DownloadRetryPolicy: &configurationRetryPolicy{
+		MaxAttempts: &sourceAttempts,
+		Jitter:      &sourceJitter,
+		RetryCodes:  &retryCodes,
+	}}
+	policy = getRetryPolicy(source)
+	if policy.MaxAttempts != 8 {
+		t.Errorf("source attempts not over global: %d", policy.MaxAttempts)
+	}
+	if policy.Backoff != 2*time.Second {
+		t.Errorf("global backoff not kept: %s", policy.Backoff)
+	}
+	if policy.Jitter != 1 {
+		t.Errorf("jitter not clamped: %f", policy.Jitter)
+	}
+	if len(policy.RetryCodes) != 2 || len(policy.NeverRetryCodes) != len(defaultNeverRetryCodes) {
+		t.Errorf("codes: %v / %v", policy.RetryCodes, policy.NeverRetryCodes)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{Backoff: time.Second, BackoffMax: 5 * time.Second}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := policy.delay(retry); got != want {
+			t.Errorf("delay(%d) = %s, want %s", retry, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.delay(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("jittered delay out of range: %s", got)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := retryPolicy{NeverRetryCodes: defaultNeverRetryCodes}
+	tests := []struct {
+		status downloadStatusStruct
+		want   bool
+	}{
+		{downloadStatusStruct{Status: downloadSuccess}, false},
+		{downloadStatusStruct{Status: downloadSkippedDuplicate}, false},
+		{downloadStatusStruct{Status: downloadFailedInvalidSource}, false},
+		{downloadStatusStruct{Status: downloadFailedInvalidPath}, false},
+		{downloadStatusStruct{Status: downloadFailed}, true},
+		{downloadStatusStruct{Status: downloadFailedCode, StatusCode: 503}, true},
+		{downloadStatusStruct{Status: downloadFailedCode404, StatusCode: 404}, false},
+	}
+	for _, test := range tests {
+		if got := policy.shouldRetry(test.status); got != test.want {
+			t.Errorf("shouldRetry(%+v) = %t, want %t", test.status, got, test.want)
+		}
+	}
+
+	policy.RetryCodes = []string{"429", "5XX"}
+	for code, want := range map[int]bool{429: true, 502: true, 400: false, 404: false} {
+		if got := policy.shouldRetry(downloadStatusStruct{Status: downloadFailedCode, StatusCode: code}); got != want {
+			t.Errorf("HTTP %d with retry codes: %t, want %t", code, got, want)
+		}
+	}
+}
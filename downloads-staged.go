@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Files already on disk that still need to go through tryDownload (filters, naming, database),
+// like ones fetched by an external tool. They're queued as ddg-staged://<token>/<filename> links,
+// the token is random so a link posted in chat can't point the bot at anything on disk.
+
+const (
+	stagedScheme = "ddg-staged"
+	// Anything never picked up (pruned, filtered out before queueing) is cleaned up after this.
+	stagedFileExpiry = time.Hour
+)
+
+type stagedFile struct {
+	Path      string
+	SourceURL string // page the file came from, stored in the database in place of the staged link
+	Time      time.Time
+}
+
+var (
+	stagedFilesMutex sync.Mutex
+	stagedFiles      = map[string]*stagedFile{}
+)
+
+// stageFile registers a local file and returns the link to queue it with.
+func stageFile(filePath string, sourceURL string) string {
+	tokenBytes := make([]byte, 16)
+	rand.Read(tokenBytes)
+	token := hex.EncodeToString(tokenBytes)
+
+	stagedFilesMutex.Lock()
+	defer stagedFilesMutex.Unlock()
+	for oldToken, old := range stagedFiles {
+		if time.Since(old.Time) > stagedFileExpiry {
+			removeStagedFile(old)
+			delete(stagedFiles, oldToken)
+		}
+	}
+	stagedFiles[token] = &stagedFile{filePath, sourceURL, time.Now()}
+	return stagedScheme + "://" + token + "/" + url.PathEscape(filepath.Base(filePath))
+}
+
+func isStagedLink(link string) bool {
+	return strings.HasPrefix(link, stagedScheme+"://")
+}
+
+func getStagedFile(link string) *stagedFile {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Scheme != stagedScheme {
+		return nil
+	}
+	stagedFilesMutex.Lock()
+	defer stagedFilesMutex.Unlock()
+	return stagedFiles[parsed.Host]
+}
+
+// releaseStagedFile deletes the file once it's been handled, whatever the outcome.
+func releaseStagedFile(link string) {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Scheme != stagedScheme {
+		return
+	}
+	stagedFilesMutex.Lock()
+	defer stagedFilesMutex.Unlock()
+	if staged, exists := stagedFiles[parsed.Host]; exists {
+		removeStagedFile(staged)
+		delete(stagedFiles, parsed.Host)
+	}
+}
+
+func removeStagedFile(staged *stagedFile) {
+	if err := os.Remove(staged.Path); err != nil && !os.IsNotExist(err) {
+		log.Println(lg("Download", "Staged", color.RedString, "Failed to remove staged file \"%s\": %s", staged.Path, err))
+	}
+	os.Remove(filepath.Dir(staged.Path)) // only goes once the directory is empty
+}
+
+// openStagedResponse wraps a staged file as a response, so it takes the same path as anything downloaded.
+func openStagedResponse(staged *stagedFile, link string) (*http.Response, error) {
+	file, err := os.Open(staged.Path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	parsed, _ := url.Parse(link)
+	parsed.Path = path.Clean(parsed.Path)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          file,
+		ContentLength: stat.Size(),
+		Request:       &http.Request{Method: "GET", URL: parsed, Header: http.Header{}},
+	}, nil
+}
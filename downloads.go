@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"io"
@@ -40,6 +43,11 @@ type downloadItem struct {
 	Filename    string
 	ChannelID   string
 	UserID      string
+	Hash        string // SHA-256 of the content
+	// URL with rewrites applied & tracking removed, see common-url.go
+	CanonicalURL string
+	// Message the file was posted in, when it came through a link to it rather than the message itself
+	OriginMessageID string
 }
 
 type downloadStatus int
@@ -57,6 +65,13 @@ const (
 	downloadSkippedUnpermittedReaction
 	downloadSkippedUnpermittedType
 	downloadSkippedDetectedDuplicate
+	downloadSkippedDuplicateContent
+	downloadSkippedDuplicateContentLinked
+	downloadSkippedFileSizeTooSmall
+	downloadSkippedFileSizeTooLarge
+	downloadSkippedDimensionsTooSmall
+	downloadSkippedDimensionsTooLarge
+	downloadSkippedAspectRatio
 
 	downloadFailed
 	downloadFailedCode
@@ -74,8 +89,9 @@ const (
 )
 
 type downloadStatusStruct struct {
-	Status downloadStatus
-	Error  error
+	Status     downloadStatus
+	Error      error
+	StatusCode int // HTTP status, if the failure came from one
 }
 
 type fileItem struct {
@@ -83,6 +99,23 @@ type fileItem struct {
 	Filename     string
 	AttachmentID string
 	Time         time.Time
+	PostTime     time.Time         // when the linked post was made, for extractors that know
+	DataKeys     map[string]string // extra filename & subfolder keys from extractors, named without braces
+	// Message the file actually came from, when found through a link, reply or forward
+	OriginMessageID string
+	OriginType      string // link, reply or forward, empty when posted directly
+	// Set for files that take more than a request to get, Link being the page they're from.
+	// Run by the download worker, the files it comes up with are handled in place of this one.
+	Resolve func() ([]*fileItem, error)
+}
+
+// For extractors that only have links & filenames
+func fileItemsFromLinks(links map[string]string) []*fileItem {
+	var items []*fileItem
+	for link, filename := range links {
+		items = append(items, &fileItem{Link: link, Filename: filename})
+	}
+	return items
 }
 
 func mDownloadStatus(status downloadStatus, _error ...error) downloadStatusStruct {
@@ -122,6 +155,20 @@ func getDownloadStatus(status downloadStatus) string {
 		return "Skipped - Unpermitted File Type"
 	case downloadSkippedDetectedDuplicate:
 		return "Skipped - Detected Duplicate"
+	case downloadSkippedDuplicateContent:
+		return "Skipped - Duplicate Content"
+	case downloadSkippedDuplicateContentLinked:
+		return "Skipped - Duplicate Content (Hardlinked)"
+	case downloadSkippedFileSizeTooSmall:
+		return "Skipped - File Too Small"
+	case downloadSkippedFileSizeTooLarge:
+		return "Skipped - File Too Large"
+	case downloadSkippedDimensionsTooSmall:
+		return "Skipped - Dimensions Too Small"
+	case downloadSkippedDimensionsTooLarge:
+		return "Skipped - Dimensions Too Large"
+	case downloadSkippedAspectRatio:
+		return "Skipped - Unpermitted Aspect Ratio"
 	//
 	case downloadFailed:
 		return "Failed"
@@ -184,13 +231,13 @@ func trimDuplicateLinks(fileItems []*fileItem) []*fileItem {
 }
 
 // Trim files already downloaded and stored in database
-func pruneCompletedLinks(linkList map[string]string, m *discordgo.Message) map[string]string {
+func pruneCompletedLinks(items []*fileItem, m *discordgo.Message) []*fileItem {
 	sourceConfig := getSource(m)
 
-	newList := make(map[string]string, 0)
-	for link, filename := range linkList {
+	var newList []*fileItem
+	for _, item := range items {
 		alreadyDownloaded := false
-		for _, downloadedFile := range dbFindDownloadByURL(link) {
+		for _, downloadedFile := range dbFindDownloadByURL(item.Link) {
 			if downloadedFile.ChannelID == m.ChannelID {
 				alreadyDownloaded = true
 			}
@@ -202,14 +249,80 @@ func pruneCompletedLinks(linkList map[string]string, m *discordgo.Message) map[s
 		}
 
 		if !alreadyDownloaded || savePossibleDuplicates {
-			newList[link] = filename
+			newList = append(newList, item)
 		} else if config.Debug {
-			log.Println(lg("Download", "SKIP", color.GreenString, "Found URL has already been downloaded for this channel: %s", link))
+			log.Println(lg("Download", "SKIP", color.GreenString, "Found URL has already been downloaded for this channel: %s", item.Link))
 		}
 	}
 	return newList
 }
 
+func parseFileSizeFilter(value *string) (int64, bool) {
+	if value == nil || *value == "" {
+		return 0, false
+	}
+	size, err := humanize.ParseBytes(*value)
+	if err != nil {
+		log.Println(lg("Download", "", color.RedString, "Invalid file size filter \"%s\": %s", *value, err))
+		return 0, false
+	}
+	return int64(size), true
+}
+
+// checkFileSizeFilters returns the skip status for size, or downloadSuccess when it's permitted.
+func checkFileSizeFilters(filters *configurationSourceFilters, size int64) downloadStatus {
+	if minSize, ok := parseFileSizeFilter(filters.MinFileSize); ok && size < minSize {
+		return downloadSkippedFileSizeTooSmall
+	}
+	if maxSize, ok := parseFileSizeFilter(filters.MaxFileSize); ok && size > maxSize {
+		return downloadSkippedFileSizeTooLarge
+	}
+	return downloadSuccess
+}
+
+func hasDimensionFilters(filters *configurationSourceFilters) bool {
+	return filters.MinWidth != nil || filters.MaxWidth != nil ||
+		filters.MinHeight != nil || filters.MaxHeight != nil ||
+		filters.MinAspectRatio != nil || filters.MaxAspectRatio != nil
+}
+
+// checkDimensionFilters returns the skip status for the given size, or downloadSuccess when it's permitted.
+func checkDimensionFilters(filters *configurationSourceFilters, width int, height int) downloadStatus {
+	if (filters.MinWidth != nil && width < *filters.MinWidth) ||
+		(filters.MinHeight != nil && height < *filters.MinHeight) {
+		return downloadSkippedDimensionsTooSmall
+	}
+	if (filters.MaxWidth != nil && width > *filters.MaxWidth) ||
+		(filters.MaxHeight != nil && height > *filters.MaxHeight) {
+		return downloadSkippedDimensionsTooLarge
+	}
+	if height > 0 {
+		ratio := float64(width) / float64(height)
+		if (filters.MinAspectRatio != nil && ratio < *filters.MinAspectRatio) ||
+			(filters.MaxAspectRatio != nil && ratio > *filters.MaxAspectRatio) {
+			return downloadSkippedAspectRatio
+		}
+	}
+	return downloadSuccess
+}
+
+// Find a saved file with the same content, within the same source if the scope asks for it
+func findDuplicateContent(hash string, sourceConfig configurationSource, channelID string) *downloadItem {
+	for _, downloaded := range dbFindDownloadByHash(hash) {
+		if _, err := os.Stat(downloaded.Destination); err != nil {
+			continue // moved, deleted or never saved
+		}
+		if *sourceConfig.DuplicateContentScope == "source" && downloaded.ChannelID != channelID {
+			if _, err := getChannel(downloaded.ChannelID); err != nil ||
+				getSourceKey(getSource(&discordgo.Message{ChannelID: downloaded.ChannelID})) != getSourceKey(sourceConfig) {
+				continue
+			}
+		}
+		return downloaded
+	}
+	return nil
+}
+
 func getRawLinks(m *discordgo.Message) []*fileItem {
 	var links []*fileItem
 
@@ -233,18 +346,11 @@ func getRawLinks(m *discordgo.Message) []*fileItem {
 			links = append(links, &fileItem{
 				Link: embed.URL,
 			})
-		}
-
-		// Description checking removed because it causes absolute chaos,
-		// fetching every random link from the description of things like YouTube videos.
-		/*if embed.Description != "" {
-			foundLinks = xurls.Strict().FindAllString(embed.Description, -1)
-			for _, foundLink := range foundLinks {
-				links = append(links, &fileItem{
-					Link: foundLink,
-				})
+			// The rest is a low quality preview, the page gets the original through its extractor
+			if isGIFProviderPage(embed.URL) {
+				continue
 			}
-		}*/
+		}
 
 		if embed.Image != nil && embed.Image.URL != "" {
 			links = append(links, &fileItem{
@@ -267,162 +373,69 @@ func getRawLinks(m *discordgo.Message) []*fileItem {
 		})
 	}
 
-	return links
+	return append(links, getEmbedTextLinks(m)...)
 }
 
-func getParsedLinks(inputURL string, m *discordgo.Message) map[string]string {
-	/* TODO: Download Support...
-	- TikTok: Tried, once the connection is closed the cdn URL is rendered invalid
-	- Facebook Photos: Tried, it doesn't preload image data, it's loaded in after. Would have to keep connection open, find alternative way to grab, or use api.
-	- Facebook Videos: Previously supported but they split mp4 into separate audio and video streams
-	*/
-
-	// Twitter / X
-	inputURL = strings.ReplaceAll(inputURL, "mobile.twitter", "twitter")
-	inputURL = strings.ReplaceAll(inputURL, "fxtwitter.com", "twitter.com")
-	inputURL = strings.ReplaceAll(inputURL, "c.vxtwitter.com", "twitter.com")
-	inputURL = strings.ReplaceAll(inputURL, "vxtwitter.com", "twitter.com")
-	inputURL = strings.ReplaceAll(inputURL, "//x.com", "//twitter.com")
-	inputURL = strings.ReplaceAll(inputURL, ".x.com", ".twitter.com")
-	if twitterConnected {
-		if regexUrlTwitter.MatchString(inputURL) {
-			links, err := getTwitterUrls(inputURL)
-			if err != nil {
-				if !strings.Contains(err.Error(), "suspended") {
-					log.Println(lg("Download", "", color.RedString, "Twitter Media fetch failed for %s -- %s", inputURL, err))
-				}
-			} else if len(links) > 0 {
-				return pruneCompletedLinks(links, m)
-			}
-		}
-		if regexUrlTwitterStatus.MatchString(inputURL) {
-			links, err := getTwitterStatusUrls(inputURL, m)
-			if err != nil {
-				if !strings.Contains(err.Error(), "suspended") && !strings.Contains(err.Error(), "No status found") {
-					log.Println(lg("Download", "", color.RedString, "Twitter Status fetch failed for %s -- %s", inputURL, err))
-				}
-			} else if len(links) > 0 {
-				return pruneCompletedLinks(links, m)
-			}
-		}
-	} else if strings.Contains(inputURL, "twitter.com") {
-		return pruneCompletedLinks(map[string]string{inputURL: ""}, m)
-	}
-
-	// Instagram
-	if instagramConnected {
-		if regexUrlInstagram.MatchString(inputURL) || regexUrlInstagramReel.MatchString(inputURL) {
-			if strings.Contains(inputURL, "?") {
-				inputURL = inputURL[:strings.Index(inputURL, "?")]
-			}
-			links, err := getInstagramUrls(inputURL, m)
-			if err != nil {
-				log.Println(lg("Download", "", color.RedString, "Instagram media fetch failed for %s -- %s", inputURL, err))
-			} else if len(links) > 0 {
-				return pruneCompletedLinks(links, m)
-			}
-		}
-	}
+const defaultEmbedTextMaxLinks = 10
 
-	// Imgur (Legacy from DIDG)
-	if regexUrlImgurSingle.MatchString(inputURL) {
-		links, err := getImgurSingleUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Imgur Media fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
-		}
-	}
-	if regexUrlImgurAlbum.MatchString(inputURL) {
-		links, err := getImgurAlbumUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Imgur Album fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
-		}
+// Scanning embed text used to be on for everyone and fetched every random link in things like YouTube
+// descriptions, so now it's opt-in and only for listed domains.
+func getEmbedTextLinks(m *discordgo.Message) []*fileItem {
+	if len(m.Embeds) == 0 {
+		return nil
 	}
-
-	// Streamable (Legacy from DIDG)
-	if regexUrlStreamable.MatchString(inputURL) {
-		links, err := getStreamableUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Streamable fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
-		}
+	settings := getSource(m).ScanEmbedText
+	if settings == nil || len(settings.Domains) == 0 {
+		return nil
 	}
-
-	// Gfycat (Legacy from DIDG)
-	if regexUrlGfycat.MatchString(inputURL) {
-		links, err := getGfycatUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Gfycat fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
-		}
+	maxLinks := defaultEmbedTextMaxLinks
+	if settings.MaxLinks != nil {
+		maxLinks = *settings.MaxLinks
 	}
 
-	// Flickr (Legacy from DIDG)
-	if regexUrlFlickrPhoto.MatchString(inputURL) {
-		links, err := getFlickrPhotoUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Flickr Photo fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+	var links []*fileItem
+	add := func(link string) bool {
+		if len(links) >= maxLinks {
+			return false
 		}
-	}
-	if regexUrlFlickrAlbum.MatchString(inputURL) {
-		links, err := getFlickrAlbumUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Flickr Album fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+		if parsed, err := url.Parse(link); err == nil && hostInDomains(parsed.Hostname(), settings.Domains) {
+			links = append(links, &fileItem{Link: link})
 		}
+		return true
 	}
-	if regexUrlFlickrAlbumShort.MatchString(inputURL) {
-		links, err := getFlickrAlbumShortUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Flickr Album (short) fetch failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+	for _, embed := range m.Embeds {
+		texts := []string{embed.Description}
+		for _, field := range embed.Fields {
+			texts = append(texts, field.Name, field.Value)
 		}
-	}
-
-	// Tistory (Legacy from DIDG)
-	if regexUrlTistory.MatchString(inputURL) {
-		links, err := getTistoryUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Tistory URL failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+		if embed.Footer != nil {
+			texts = append(texts, embed.Footer.Text)
 		}
-	}
-	if regexUrlTistoryLegacy.MatchString(inputURL) {
-		links, err := getLegacyTistoryUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Legacy Tistory URL failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+		if embed.Author != nil && embed.Author.URL != "" && !add(embed.Author.URL) {
+			break
 		}
-	}
-	// The original project has this as an option,
-	if regexUrlPossibleTistorySite.MatchString(inputURL) {
-		links, err := getPossibleTistorySiteUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Checking for Tistory site failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
+		for _, text := range texts {
+			for _, link := range xurls.Strict().FindAllString(text, -1) {
+				if !add(link) {
+					return links
+				}
+			}
 		}
 	}
+	return links
+}
 
-	// Reddit
-	if regexUrlRedditPost.MatchString(inputURL) {
-		links, err := getRedditPostUrls(inputURL)
-		if err != nil {
-			log.Println(lg("Download", "", color.RedString, "Reddit Post URL failed for %s -- %s", inputURL, err))
-		} else if len(links) > 0 {
-			return pruneCompletedLinks(links, m)
-		}
+func getParsedLinks(inputURL string, m *discordgo.Message) []*fileItem {
+	/* TODO: Download Support...
+	- TikTok: Tried, once the connection is closed the cdn URL is rendered invalid
+	- Facebook Photos: Tried, it doesn't preload image data, it's loaded in after. Would have to keep connection open, find alternative way to grab, or use api.
+	- Facebook Videos: Previously supported but they split mp4 into separate audio and video streams
+	*/
+
+	// Site extractors, see extractors.go. They match on the canonical link (mirrors, tracking & short
+	// links, see common-url.go), anything left for a direct download is fetched as it was posted.
+	if items := runExtractors(canonicalizeLink(requestContext(m), inputURL), m); len(items) > 0 {
+		return pruneCompletedLinks(items, m)
 	}
 
 	// Ignore Discord emojis / stickers
@@ -445,32 +458,127 @@ func getParsedLinks(inputURL string, m *discordgo.Message) map[string]string {
 		}
 	}
 
-	return pruneCompletedLinks(map[string]string{inputURL: ""}, m)
+	return pruneCompletedLinks([]*fileItem{{Link: inputURL}}, m)
 }
 
 func getLinksByMessage(m *discordgo.Message) []*fileItem {
+	return getLinksByMessageFrom(m, m.ChannelID, 0)
+}
+
+// getLinksByMessageFrom is getLinksByMessage for a message looked through as if it were in another
+// channel, channelID being the one it's actually in and depth how many message links deep it was found.
+func getLinksByMessageFrom(m *discordgo.Message, channelID string, depth int) []*fileItem {
+	fileItems := getOwnLinksByMessage(m, depth)
+
+	sourceConfig := getSource(m)
+	if sourceConfig.IncludeReplies != nil && *sourceConfig.IncludeReplies &&
+		m.Type == discordgo.MessageTypeReply && m.ReferencedMessage != nil {
+		fileItems = append(fileItems, getOriginLinks(m, m.ReferencedMessage, "reply", depth)...)
+	}
+	if sourceConfig.IncludeForwards != nil && *sourceConfig.IncludeForwards && isPossibleForward(m) {
+		for _, snapshot := range getMessageSnapshots(channelID, m.ID) {
+			fileItems = append(fileItems, getOriginLinks(m, snapshot, "forward", depth)...)
+		}
+	}
+
+	return trimDuplicateLinks(fileItems)
+}
+
+// Files in the message itself, not counting replies & forwards
+func getOwnLinksByMessage(m *discordgo.Message, depth int) []*fileItem {
 	var fileItems []*fileItem
 
 	linkTime := m.Timestamp
 
 	rawLinks := getRawLinks(m)
 	for _, rawLink := range rawLinks {
-		downloadLinks := getParsedLinks(rawLink.Link, m)
-		for link, filename := range downloadLinks {
+		var items []*fileItem
+		if depth > 0 && regexUrlDiscordMessage.MatchString(rawLink.Link) {
+			// A level further down, rather than through the extractors as if it were a top level link
+			items = getNestedDiscordMessageFiles(rawLink.Link, m, depth+1)
+		} else {
+			items = getParsedLinks(rawLink.Link, m)
+		}
+		for _, item := range items {
 			if rawLink.Filename != "" {
-				filename = rawLink.Filename
+				item.Filename = rawLink.Filename
 			}
+			item.Time = linkTime
+			if item.AttachmentID == "" {
+				item.AttachmentID = rawLink.AttachmentID
+			}
+			fileItems = append(fileItems, item)
+		}
+	}
 
-			fileItems = append(fileItems, &fileItem{
-				Link:         link,
-				Filename:     filename,
-				Time:         linkTime,
-				AttachmentID: rawLink.AttachmentID,
-			})
+	return fileItems
+}
+
+// Files from a message that m replied to or forwarded, skipping any already saved from the original.
+func getOriginLinks(m *discordgo.Message, origin *discordgo.Message, originType string, depth int) []*fileItem {
+	// Looked through as if it were in m's channel, so its settings apply
+	relocated := *origin
+	relocated.ChannelID = m.ChannelID
+	relocated.GuildID = m.GuildID
+
+	savePossibleDuplicates := false
+	if sourceConfig := getSource(m); sourceConfig.SavePossibleDuplicates != nil {
+		savePossibleDuplicates = *sourceConfig.SavePossibleDuplicates
+	}
+
+	var fileItems []*fileItem
+	for _, item := range getOwnLinksByMessage(&relocated, depth) {
+		if !savePossibleDuplicates && len(dbFindDownloadByURL(item.Link)) > 0 {
+			if config.Debug {
+				log.Println(lg("Download", "SKIP", color.GreenString, "Found %s file already saved from the original: %s", originType, item.Link))
+			}
+			continue
+		}
+		item.OriginType = originType
+		if item.OriginMessageID == "" {
+			item.OriginMessageID = origin.ID
 		}
+		fileItems = append(fileItems, item)
 	}
+	return fileItems
+}
 
-	return trimDuplicateLinks(fileItems)
+// Our fork's Message has neither the reference's type nor the snapshots, but forwards are the only
+// plain messages with a reference besides crossposts, and carry nothing of their own.
+func isPossibleForward(m *discordgo.Message) bool {
+	return m.Type == discordgo.MessageTypeDefault && m.MessageReference != nil &&
+		m.Flags&discordgo.MessageFlagsIsCrossPosted == 0 &&
+		m.Content == "" && len(m.Attachments) == 0 && len(m.Embeds) == 0
+}
+
+// Forwards are fetched again raw for their snapshots, from the channel they were sent to.
+func getMessageSnapshots(channelID string, messageID string) []*discordgo.Message {
+	body, err := bot.Request("GET", discordgo.EndpointChannelMessage(channelID, messageID), nil)
+	if err != nil {
+		log.Println(lg("Download", "Forward", color.RedString, "Failed to fetch message %s for forwarded files: %s", messageID, err))
+		return nil
+	}
+	var raw struct {
+		MessageReference *struct {
+			Type      int    `json:"type"` // 1 for forwards
+			MessageID string `json:"message_id"`
+		} `json:"message_reference"`
+		MessageSnapshots []struct {
+			Message *discordgo.Message `json:"message"`
+		} `json:"message_snapshots"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil || raw.MessageReference == nil || raw.MessageReference.Type != 1 {
+		return nil
+	}
+	var snapshots []*discordgo.Message
+	for _, snapshot := range raw.MessageSnapshots {
+		if snapshot.Message != nil {
+			// Snapshots only carry the content, not who or where it was from
+			snapshot.Message.ID = raw.MessageReference.MessageID
+			snapshots = append(snapshots, snapshot.Message)
+		}
+	}
+	return snapshots
 }
 
 type downloadRequestStruct struct {
@@ -481,36 +589,61 @@ type downloadRequestStruct struct {
 	Message        *discordgo.Message
 	Channel        *discordgo.Channel
 	FileTime       time.Time
+	PostTime       time.Time
 	HistoryCmd     bool
 	EmojiCmd       bool
 	ManualDownload bool
 	StartTime      time.Time
 	AttachmentID   string
+	DataKeys       map[string]string
+	// Message the file came from when it isn't Message
+	OriginMessageID string
+	OriginType      string
+	ArchiveMember   bool // unpacked from an archive, already in its folder
+	Resolve         func() ([]*fileItem, error)
 }
 
+// handleDownload makes a single attempt and wraps it up, for files that are already on disk like
+// those unpacked from an archive. Everything else goes through the queue, which retries.
 func (download downloadRequestStruct) handleDownload() (downloadStatusStruct, int64) {
-	status := mDownloadStatus(downloadFailed)
-	var tempfilesize int64 = -1
-	for i := 0; i < config.DownloadRetryMax; i++ {
-		status, tempfilesize = download.tryDownload()
-		// Success or Skip
-		if status.Status < downloadFailed || status.Status == downloadFailedCode404 || status.Status == downloadFailedCode403 {
-			break
-		} else {
-			time.Sleep(5 * time.Second)
-		}
+	status, filesize := download.tryDownload()
+	var attempts []downloadStatusStruct
+	if status.Status >= downloadFailed {
+		attempts = append(attempts, status)
+	}
+	download.finishDownload(status, attempts)
+	return status, filesize
+}
+
+// finishDownload records, reports & logs how a download turned out once there are no attempts left.
+func (download downloadRequestStruct) finishDownload(status downloadStatusStruct, attempts []downloadStatusStruct) {
+	sourceConfig := emptySourceConfig
+	if !download.EmojiCmd {
+		sourceConfig = getSource(download.Message)
+	}
+
+	// Nothing else will pick it up, successful or not
+	if isStagedLink(download.InputURL) {
+		releaseStagedFile(download.InputURL)
+	}
+
+	// Keep the failure queue current
+	if status.Status >= downloadFailed {
+		recordDownloadFailure(download, status, len(attempts))
+	} else if status.Status == downloadSuccess {
+		clearDownloadFailure(download)
 	}
 
 	// Any kind of failure
 	if status.Status >= downloadFailed && !download.HistoryCmd && !download.EmojiCmd {
 		log.Println(lg("Download", "", color.RedString,
-			"Gave up on downloading %s after %d failed attempts...\t%s",
-			download.InputURL, config.DownloadRetryMax, getDownloadStatus(status.Status)))
-		if sourceConfig := getSource(download.Message); sourceConfig != emptySourceConfig {
+			"Gave up on downloading %s after %d failed attempt%s...\n%s",
+			download.InputURL, len(attempts), pluralS(len(attempts)), formatDownloadAttempts(attempts)))
+		if sourceConfig != emptySourceConfig {
 			if !download.HistoryCmd && *sourceConfig.SendErrorMessages {
 				content := fmt.Sprintf(
-					"Gave up trying to download\n<%s>\nafter %d failed attempts...\n\n``%s``",
-					download.InputURL, config.DownloadRetryMax, getDownloadStatus(status.Status))
+					"Gave up trying to download\n<%s>\nafter %d failed attempt%s...\n\n```%s```",
+					download.InputURL, len(attempts), pluralS(len(attempts)), formatDownloadAttempts(attempts))
 				if status.Error != nil {
 					content += fmt.Sprintf("\n```ERROR: %s```", status.Error)
 				}
@@ -669,15 +802,33 @@ func (download downloadRequestStruct) handleDownload() (downloadStatusStruct, in
 			}
 		}
 	}
+}
 
-	return status, tempfilesize
+// deferredDownloads turns what a deferred file was resolved into back into download requests.
+func (download downloadRequestStruct) deferredDownloads(items []*fileItem) []downloadRequestStruct {
+	var downloads []downloadRequestStruct
+	for _, item := range items {
+		itemDownload := download
+		itemDownload.InputURL = item.Link
+		itemDownload.Filename = item.Filename
+		itemDownload.Resolve = item.Resolve
+		if item.DataKeys != nil {
+			itemDownload.DataKeys = make(map[string]string)
+			for key, value := range download.DataKeys {
+				itemDownload.DataKeys[key] = value
+			}
+			for key, value := range item.DataKeys {
+				itemDownload.DataKeys[key] = value
+			}
+		}
+		downloads = append(downloads, itemDownload)
+	}
+	return downloads
 }
 
 func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64) {
 	var err error
 
-	cachedDownloadID++
-
 	logPrefix := ""
 	if download.HistoryCmd {
 		logPrefix = "HISTORY "
@@ -701,8 +852,17 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 			return mDownloadStatus(downloadFailedInvalidSource, err), 0
 		}
 
+		// Staged files are already on disk, they're filtered & recorded by the page they came from
+		sourceURL := download.InputURL
+		staged := getStagedFile(download.InputURL)
+		if staged != nil {
+			sourceURL = staged.SourceURL
+		} else if isStagedLink(download.InputURL) {
+			return mDownloadStatus(downloadFailedInvalidSource, errors.New("staged file no longer available")), 0
+		}
+
 		// Check Domain
-		parsedURL, err := url.Parse(download.InputURL)
+		parsedURL, err := url.Parse(sourceURL)
 		if err != nil {
 			log.Println(lg("Download", "", color.RedString, "Error while parsing url:\t%s", err))
 		}
@@ -752,18 +912,25 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 		}
 
 		// Request
-		timeout := time.Duration(time.Duration(config.DownloadTimeout) * time.Second)
-		client := &http.Client{
-			Timeout: timeout,
+		ctx := withRequestTimeout(context.Background(), getRetryPolicy(sourceConfig).Timeout)
+		if sourceConfig.Proxy != nil && *sourceConfig.Proxy != "" {
+			ctx = withProxy(ctx, *sourceConfig.Proxy)
 		}
-		request, err := http.NewRequest("GET", download.InputURL, nil)
-		request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/66.0.3359.139 Safari/537.36")
+		request, err := http.NewRequestWithContext(ctx, "GET", download.InputURL, nil)
 		if err != nil {
 			log.Println(lg("Download", "", color.HiRedString, "Error while requesting \"%s\": %s", download.InputURL, err))
 			return mDownloadStatus(downloadFailedRequesting, err), 0
 		}
 		request.Header.Add("Accept-Encoding", "identity")
-		response, err := client.Do(request)
+		partial := claimPartialDownload(download.InputURL)
+		defer partial.release()
+		partial.setRangeHeaders(request)
+		var response *http.Response
+		if staged != nil {
+			response, err = openStagedResponse(staged, download.InputURL)
+		} else {
+			response, err = doRequest(request)
+		}
 		if err != nil {
 			if !strings.Contains(err.Error(), "no such host") && !strings.Contains(err.Error(), "connection refused") {
 				log.Println(lg("Download", "", color.HiRedString,
@@ -774,36 +941,92 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 		}
 		defer response.Body.Close()
 
-		// Read
-		bodyOfResp, err := io.ReadAll(response.Body)
-		if err != nil {
-			log.Println(lg("Download", "", color.HiRedString,
-				"Could not read response from \"%s\": %s",
-				download.InputURL, err))
-			return mDownloadStatus(downloadFailedReadResponse, err), 0
-		}
-
 		// Errors
 		if response.StatusCode >= 400 {
 			log.Println(lg("Download", "", color.HiRedString, logPrefix+"DOWNLOAD FAILED, %d %s: %s",
 				response.StatusCode, http.StatusText(response.StatusCode), download.InputURL))
+			// Hold on to partial progress through server hiccups, anything else won't be resumed.
+			if response.StatusCode != http.StatusRequestTimeout && response.StatusCode != http.StatusTooManyRequests &&
+				response.StatusCode < 500 {
+				partial.remove()
+			}
+			status := mDownloadStatus(downloadFailedCode, err)
 			if response.StatusCode == 403 {
-				return mDownloadStatus(downloadFailedCode403, err), 0
+				status.Status = downloadFailedCode403
 			} else if response.StatusCode == 404 {
-				return mDownloadStatus(downloadFailedCode404, err), 0
-			} else {
-				return mDownloadStatus(downloadFailedCode, err), 0
+				status.Status = downloadFailedCode404
+			}
+			status.StatusCode = response.StatusCode
+			return status, 0
+		}
+
+		// Resume or start over
+		keepPartial := false
+		defer func() {
+			if !keepPartial {
+				partial.remove()
+			}
+		}()
+		resuming := partial.acceptsResume(response)
+		if resuming {
+			log.Println(lg("Download", "", color.CyanString, logPrefix+"Resuming %s from %s",
+				download.InputURL, humanize.Bytes(uint64(partial.Size))))
+		} else {
+			if partial.resumable() {
+				log.Println(lg("Download", "", color.YellowString,
+					logPrefix+"Could not resume %s, file changed or server refused... Starting over",
+					download.InputURL))
+			}
+			partial.reset(response)
+		}
+
+		// Check size, early when the server tells us
+		if response.ContentLength >= 0 {
+			expectedSize := response.ContentLength
+			if resuming {
+				expectedSize += partial.Size
+			}
+			if status := checkFileSizeFilters(sourceConfig.Filters, expectedSize); status != downloadSuccess {
+				if !download.HistoryCmd {
+					log.Println(lg("Download", "Skip", color.GreenString,
+						"Unpermitted filesize (%s) found at %s", humanize.Bytes(uint64(expectedSize)), download.InputURL))
+				}
+				return mDownloadStatus(status), 0
 			}
 		}
 
+		// Read the head of the body for sniffing, the rest is streamed to disk once the file is permitted.
+		var bodyHead []byte
+		var bodyRest io.Reader = response.Body
+		if resuming {
+			bodyHead, err = partial.head()
+		} else {
+			bodyHead = make([]byte, partialDownloadHeadSize)
+			var bodyHeadLen int
+			bodyHeadLen, err = io.ReadFull(response.Body, bodyHead)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = nil
+			}
+			bodyHead = bodyHead[:bodyHeadLen]
+			bodyRest = io.MultiReader(bytes.NewReader(bodyHead), response.Body)
+		}
+		if err != nil {
+			log.Println(lg("Download", "", color.HiRedString,
+				"Could not read response from \"%s\": %s",
+				download.InputURL, err))
+			return mDownloadStatus(downloadFailedReadResponse, err), 0
+		}
+
 		// Content Type
-		contentType := http.DetectContentType(bodyOfResp)
-		contentTypeParts := strings.Split(contentType, "/")
-		contentTypeBase := contentTypeParts[0]
+		detectedType := detectFileType(bodyHead)
+		contentType := detectedType.MIME
+		contentTypeBase := detectedType.base()
 		isHtml := strings.Contains(contentType, "text/html")
 
 		// Filename
-		if download.Filename == "" {
+		if download.Filename == "" && staged != nil {
+			download.Filename = filepath.Base(staged.Path)
+		} else if download.Filename == "" {
 			download.Filename = filenameFromURL(response.Request.URL.String())
 			for key, iHeader := range response.Header {
 				if key == "Content-Disposition" {
@@ -888,14 +1111,22 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 
 		// Extension
 		download.Extension = strings.ToLower(filepath.Ext(download.Filename))
-		if filepath.Ext(download.Filename) == "" {
-			if possibleExtension, _ := mime.ExtensionsByType(contentType); len(possibleExtension) > 0 {
-				download.Filename += possibleExtension[0]
-				download.Extension = possibleExtension[0]
+		if detectedExtension := detectedType.extension(); detectedExtension != "" {
+			if download.Extension == "" {
+				download.Filename += detectedExtension
+				download.Extension = detectedExtension
+			} else if *sourceConfig.FixExtensions && !detectedType.hasExtension(download.Extension) {
+				if config.Verbose {
+					log.Println(lg("Verbose", "Download", color.HiBlueString,
+						"Fixing extension of %s, %s content saved as %s", download.Filename, contentType, detectedExtension))
+				}
+				download.Filename = strings.TrimSuffix(download.Filename, filepath.Ext(download.Filename)) + detectedExtension
+				download.Extension = detectedExtension
 			}
 		}
 
 		// Format Keys
+		unformattedFilename := download.Filename // what archives are unpacked under
 		if !download.EmojiCmd {
 			download.Filename = dataKeysDownload(sourceConfig, download)
 		}
@@ -915,21 +1146,21 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 			download.Filename = strings.ReplaceAll(download.Filename, ".jfif", ".jpg")
 		}
 
-		// Fix content type using extension
-		if stringInSlice(download.Extension, []string{".mov"}) ||
-			stringInSlice(download.Extension, []string{".mp4"}) ||
-			stringInSlice(download.Extension, []string{".webm"}) {
-			contentTypeBase = "video"
-		} else if stringInSlice(download.Extension, []string{".psd"}) ||
-			stringInSlice(download.Extension, []string{".nef"}) ||
-			stringInSlice(download.Extension, []string{".dng"}) ||
-			stringInSlice(download.Extension, []string{".tif"}) ||
-			stringInSlice(download.Extension, []string{".tiff"}) {
-			contentTypeBase = "image"
+		// Fix content type using extension, when the content didn't match anything known
+		if contentType == "application/octet-stream" {
+			if typeFromExtension := fileTypeFromExtension(download.Extension); typeFromExtension != "" {
+				contentTypeBase = typeFromExtension
+			}
+		}
+
+		// Archives being unpacked skip the extension & type filters, the files inside go through them instead
+		archiveFormat := ""
+		if sourceConfig.ExtractArchives != nil && !download.ArchiveMember && !download.EmojiCmd {
+			archiveFormat = getArchiveFormat(detectedType, download.Extension, download.Filename)
 		}
 
 		// Check extension
-		if sourceConfig.Filters.AllowedExtensions != nil || sourceConfig.Filters.BlockedExtensions != nil {
+		if archiveFormat == "" && (sourceConfig.Filters.AllowedExtensions != nil || sourceConfig.Filters.BlockedExtensions != nil) {
 			shouldAbort := false
 			if sourceConfig.Filters.AllowedExtensions != nil {
 				shouldAbort = true
@@ -957,7 +1188,7 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 		}
 
 		// Check content type
-		if !((*sourceConfig.SaveImages && contentTypeBase == "image") ||
+		if archiveFormat == "" && !((*sourceConfig.SaveImages && contentTypeBase == "image") ||
 			(*sourceConfig.SaveVideos && contentTypeBase == "video") ||
 			(*sourceConfig.SaveAudioFiles && contentTypeBase == "audio") ||
 			(*sourceConfig.SaveTextFiles && contentTypeBase == "text" && !isHtml) ||
@@ -969,24 +1200,63 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 			return mDownloadStatus(downloadSkippedUnpermittedType), 0
 		}
 
+		// Stream to partial file, stopping once it's known to be over the limit
+		if maxSize, ok := parseFileSizeFilter(sourceConfig.Filters.MaxFileSize); ok {
+			bodyRest = io.LimitReader(bodyRest, maxSize-partial.Size+1)
+		}
+		if status := partial.write(bodyRest); status.Status != downloadSuccess {
+			log.Println(lg("Download", "", color.HiRedString,
+				"Error while streaming \"%s\" to disk: %s", download.InputURL, status.Error))
+			// Worth another go from where it stopped
+			keepPartial = status.Status == downloadFailedReadResponse && partial.validator() != ""
+			return status, 0
+		}
+
+		// Check size, for when it wasn't known upfront
+		if status := checkFileSizeFilters(sourceConfig.Filters, partial.Size); status != downloadSuccess {
+			if !download.HistoryCmd {
+				log.Println(lg("Download", "Skip", color.GreenString,
+					"Unpermitted filesize found at %s", download.InputURL))
+			}
+			return mDownloadStatus(status), 0
+		}
+
+		// Check dimensions
+		if (contentTypeBase == "image" || contentTypeBase == "video") && hasDimensionFilters(sourceConfig.Filters) {
+			if width, height, err := getMediaDimensions(partial.Path); err != nil {
+				log.Println(lg("Download", "", color.RedString,
+					"Could not read dimensions of %s, not filtering by them: %s", download.InputURL, err))
+			} else if status := checkDimensionFilters(sourceConfig.Filters, width, height); status != downloadSuccess {
+				if !download.HistoryCmd {
+					log.Println(lg("Download", "Skip", color.GreenString,
+						"Unpermitted dimensions (%dx%d) found at %s", width, height, download.InputURL))
+				}
+				return mDownloadStatus(status), 0
+			}
+		}
+
 		// Duplicate Image Filter
 		if config.Duplo && contentTypeBase == "image" && download.Extension != ".gif" && download.Extension != ".webp" {
-			img, _, err := image.Decode(bytes.NewReader(bodyOfResp))
+			img, err := decodeImageFile(partial.Path)
 			if err != nil {
 				log.Println(lg("Duplo", "Download", color.HiRedString,
-					"Error converting buffer to image for hashing:\t%s", err))
+					"Error decoding file to image for hashing:\t%s", err))
 			} else {
 				hash, _ := duplo.CreateHash(img)
+				duploMutex.Lock()
 				matches := duploCatalog.Query(hash)
 				sort.Sort(matches)
 				for _, match := range matches {
 					if match.Score < config.DuploThreshold {
+						duploMutex.Unlock()
 						log.Println(lg("Duplo", "Download", color.GreenString,
 							"Duplicate detected (Score of %f) found at %s", match.Score, download.InputURL))
 						return mDownloadStatus(downloadSkippedDetectedDuplicate), 0
 					}
 				}
+				cachedDownloadID++
 				duploCatalog.Add(cachedDownloadID, hash)
+				duploMutex.Unlock()
 			}
 		}
 
@@ -1023,7 +1293,7 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 			}
 
 			// Subfolder Division - Format Subfolders
-			if sourceConfig.Subfolders != nil {
+			if sourceConfig.Subfolders != nil && !download.ArchiveMember {
 				keys := [][]string{
 					{"{{fileType}}",
 						contentTypeBase + "s"},
@@ -1038,6 +1308,7 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 							}
 						}
 						// all other keys ...
+						fmtSubfolder = dataKeys_Extracted(fmtSubfolder, download.DataKeys)
 						fmtSubfolder = dataKeys_DiscordMessage(fmtSubfolder, download.Message)
 					}
 
@@ -1064,7 +1335,23 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 		}
 		completePath := filepath.Clean(download.Path + download.Filename)
 
+		userID := botUser.ID
+		chID := "0"
+		if !download.EmojiCmd {
+			if download.Message.Author != nil {
+				userID = download.Message.Author.ID
+			}
+			chID = download.Message.ChannelID
+		}
+
 		// Check if filepath exists
+		downloadPathMutex.Lock()
+		pathLocked := true
+		defer func() {
+			if pathLocked {
+				downloadPathMutex.Unlock()
+			}
+		}()
 		if _, err := os.Stat(completePath); err == nil {
 			if *sourceConfig.SavePossibleDuplicates {
 				tmpPath := completePath
@@ -1092,16 +1379,63 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 			}
 		}
 
+		// Duplicate Content, nothing to link when the source isn't saving files
+		if mode := *sourceConfig.DuplicateContent; mode == "skip" || (mode == "hardlink" && *sourceConfig.Save) {
+			if existing := findDuplicateContent(partial.SHA256, sourceConfig, chID); existing != nil {
+				if mode == "skip" {
+					if !download.HistoryCmd && !download.EmojiCmd {
+						log.Println(lg("Download", "Skip", color.GreenString,
+							"Content of %s was already saved as \"%s\"", download.InputURL, existing.Destination))
+					}
+					return mDownloadStatus(downloadSkippedDuplicateContent), 0
+				}
+				if err = os.Link(existing.Destination, completePath); err != nil {
+					log.Println(lg("Download", "", color.RedString,
+						"Error hardlinking \"%s\" to \"%s\", saving a copy instead: %s",
+						existing.Destination, completePath, err))
+				} else {
+					if !download.HistoryCmd && !download.EmojiCmd {
+						log.Println(lg("Download", "", color.GreenString,
+							logPrefix+"Linked %s to existing \"%s\"", download.InputURL, existing.Destination))
+					}
+					if err = dbInsertDownload(&downloadItem{
+						URL:         sourceURL,
+						Time:        time.Now(),
+						Destination: completePath,
+						Filename:    download.Filename,
+						ChannelID:   chID,
+						UserID:      userID,
+						Hash:        partial.SHA256,
+
+						OriginMessageID: download.OriginMessageID,
+					}); err != nil {
+						log.Println(lg("Download", "", color.HiRedString, "Error writing to database: %s", err))
+						return mDownloadStatus(downloadFailedWritingDatabase, err), 0
+					}
+					return mDownloadStatus(downloadSkippedDuplicateContentLinked), 0
+				}
+			}
+		}
+
 		// Write
-		if *sourceConfig.Save {
-			if err = os.WriteFile(completePath, bodyOfResp, 0644); err != nil {
+		savedPath := partial.Path
+		keepArchive := archiveFormat == "" || sourceConfig.ExtractArchives.KeepArchive == nil || *sourceConfig.ExtractArchives.KeepArchive
+		if *sourceConfig.Save && keepArchive {
+			if err = partial.moveTo(completePath); err != nil {
 				log.Println(lg("Download", "", color.HiRedString,
-					"Error while writing file to disk \"%s\": %s", download.InputURL, err))
+					"Error while moving file into place \"%s\": %s", download.InputURL, err))
 				return mDownloadStatus(downloadFailedWritingFile, err), 0
 			}
+			savedPath = completePath
+			downloadPathMutex.Unlock()
+			pathLocked = false
 
 			// Change file time
-			if err = os.Chtimes(completePath, download.FileTime, download.FileTime); err != nil {
+			fileTime := download.FileTime
+			if *sourceConfig.UsePostTime && !download.PostTime.IsZero() {
+				fileTime = download.PostTime
+			}
+			if err = os.Chtimes(completePath, fileTime, fileTime); err != nil {
 				log.Println(lg("Download", "", color.RedString,
 					logPrefix+"Error while changing metadata date \"%s\": %s", download.InputURL, err))
 			}
@@ -1138,37 +1472,45 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 							filesize, timeSinceShort(download.StartTime), speed/time.Since(download.StartTime).Seconds(), speedlabel))))
 			}
 		} else {
-			if !download.EmojiCmd {
+			downloadPathMutex.Unlock()
+			pathLocked = false
+			if !keepArchive {
+				log.Println(lg("Download", "", color.GreenString,
+					logPrefix+"Unpacking %s archive sent in %s#%s without keeping it...",
+					archiveFormat, sourceName, sourceChannelName))
+			} else if !download.EmojiCmd {
 				log.Println(lg("Download", "", color.GreenString,
 					logPrefix+"Did not save %s sent in %s#%s --- file saving disabled...",
 					contentTypeBase, sourceName, sourceChannelName))
 			}
 		}
 
-		userID := botUser.ID
-		if !download.EmojiCmd {
-			if download.Message.Author != nil {
-				userID = download.Message.Author.ID
-			}
-		}
-		// Store in db
-		chID := "0"
-		if !download.EmojiCmd {
-			chID = download.Message.ChannelID
+		// Store in db, archives that weren't kept having nowhere they were saved
+		destination := completePath
+		if !keepArchive {
+			destination = ""
 		}
 		err = dbInsertDownload(&downloadItem{
-			URL:         download.InputURL,
+			URL:         sourceURL,
 			Time:        time.Now(),
-			Destination: completePath,
+			Destination: destination,
 			Filename:    download.Filename,
 			ChannelID:   chID,
 			UserID:      userID,
+			Hash:        partial.SHA256,
+
+			OriginMessageID: download.OriginMessageID,
 		})
 		if err != nil {
 			log.Println(lg("Download", "", color.HiRedString, "Error writing to database: %s", err))
 			return mDownloadStatus(downloadFailedWritingDatabase, err), 0
 		}
 
+		// Unpack
+		if archiveFormat != "" {
+			download.expandArchive(savedPath, archiveFormat, unformattedFilename, sourceURL, download.Path, sourceConfig.ExtractArchives, sourceConfig)
+		}
+
 		// React
 		if !download.EmojiCmd {
 			shouldReact := config.ReactWhenDownloaded
@@ -1255,12 +1597,16 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 						}
 						// File
 						if actualFile {
-							_, err := bot.ChannelMessageSendComplex(logChannel,
-								&discordgo.MessageSend{
-									Content: msg,
-									File:    &discordgo.File{Name: download.Filename, Reader: bytes.NewReader(bodyOfResp)},
-								},
-							)
+							file, err := os.Open(savedPath)
+							if err == nil {
+								_, err = bot.ChannelMessageSendComplex(logChannel,
+									&discordgo.MessageSend{
+										Content: msg,
+										File:    &discordgo.File{Name: download.Filename, Reader: file},
+									},
+								)
+								file.Close()
+							}
 							if err != nil {
 								log.Println(lg("Download", "", color.HiRedString,
 									"File log message failed to send:\t%s", err))
@@ -1274,7 +1620,9 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 									Text:    fmt.Sprintf("%s v%s", projectName, projectVersion),
 								},
 							}
-							if contentTypeBase == "image" {
+							if staged != nil {
+								embed.Description = sourceURL
+							} else if contentTypeBase == "image" {
 								embed.Image = &discordgo.MessageEmbedImage{URL: download.InputURL}
 							} else if contentTypeBase == "video" {
 								embed.Video = &discordgo.MessageEmbedVideo{URL: download.InputURL}
@@ -1308,11 +1656,23 @@ func (download downloadRequestStruct) tryDownload() (downloadStatusStruct, int64
 
 		timeLastDownload = time.Now()
 		if *sourceConfig.Save {
-			return mDownloadStatus(downloadSuccess), fileinfo.Size()
-		} else {
-			return mDownloadStatus(downloadSuccess), 0
+			// Archives that weren't kept are still in the partial file until this returns
+			if fileinfo, err = os.Stat(savedPath); err == nil {
+				return mDownloadStatus(downloadSuccess), fileinfo.Size()
+			}
 		}
+		return mDownloadStatus(downloadSuccess), 0
 	}
 
 	return mDownloadStatus(downloadIgnored), 0
 }
+
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testChannelID = "100"
+
+// setupTestDownloads points the database, partial downloads & config at a temporary folder, with one
+// DM channel as the source, and returns that source for tests to adjust before passing it to useTestSource.
+func setupTestDownloads(t *testing.T) configurationSource {
+	t.Helper()
+	dir := t.TempDir()
+
+	previousDatabase, previousPartials := pathDatabaseBase, pathCachePartials
+	pathDatabaseBase = filepath.Join(dir, "database")
+	pathCachePartials = filepath.Join(dir, "partials")
+	config = defaultConfiguration()
+	openDatabase()
+	if myDB == nil {
+		t.Fatal("database didn't open")
+	}
+	t.Cleanup(func() {
+		myDB.Close()
+		myDB = nil
+		pathDatabaseBase = previousDatabase
+		pathCachePartials = previousPartials
+	})
+
+	var err error
+	if bot, err = discordgo.New(""); err != nil {
+		t.Fatal(err)
+	}
+	bot.State.ChannelAdd(&discordgo.Channel{ID: testChannelID, Type: discordgo.ChannelTypeDM})
+	botUser = &discordgo.User{ID: "1"}
+
+	return configurationSource{
+		ChannelID:   testChannelID,
+		Destination: filepath.Join(dir, "downloads"),
+	}
+}
+
+func useTestSource(source configurationSource) configurationSource {
+	disabled := false
+	source.ReactWhenDownloaded = &disabled
+	source.PresenceEnabled = &disabled
+	sourceDefault(&source)
+	config.Channels = []configurationSource{source}
+	return source
+}
+
+func testDownloadRequest(link string, source configurationSource) downloadRequestStruct {
+	return downloadRequestStruct{
+		InputURL: link,
+		Path:     source.Destination,
+		Message: &discordgo.Message{
+			ID:        "200",
+			ChannelID: testChannelID,
+			Author:    &discordgo.User{ID: "2", Username: "tester"},
+			Timestamp: time.Now(),
+		},
+		FileTime:  time.Now(),
+		StartTime: time.Now(),
+	}
+}
+
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestTryDownloadArchiveNotKept(t *testing.T) {
+	source := setupTestDownloads(t)
+	keep, save, text := false, true, true
+	source.Save = &save
+	source.SaveTextFiles = &text
+	source.Subfolders = &[]string{}
+	source.ExtractArchives = &configurationArchives{KeepArchive: &keep}
+	source = useTestSource(source)
+
+	archive := testZip(t, map[string]string{"notes.txt": "unpacked from the archive"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+	link := server.URL + "/files/bundle.zip"
+
+	download := testDownloadRequest(link, source)
+	download.Filename = "bundle.zip"
+	status, size := download.tryDownload()
+	if status.Status != downloadSuccess {
+		t.Fatalf("status = %s (%v)", getDownloadStatus(status.Status), status.Error)
+	}
+	if size != int64(len(archive)) {
+		t.Errorf("size = %d, want the %d bytes downloaded", size, len(archive))
+	}
+
+	entries, _ := os.ReadDir(source.Destination)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			t.Errorf("archive wasn't to be kept, found %s", entry.Name())
+		}
+	}
+	if members, _ := filepath.Glob(filepath.Join(source.Destination, "bundle", "*notes.txt")); len(members) != 1 {
+		t.Errorf("member wasn't unpacked into the archive's folder")
+	}
+
+	records := dbFindDownloadByURL(link)
+	if len(records) != 1 {
+		t.Fatalf("found %d records for the archive, want 1", len(records))
+	}
+	if records[0].Destination != "" {
+		t.Errorf("archive recorded as saved to %s", records[0].Destination)
+	}
+}
+
+func TestFindDownloadByResignedDiscordLink(t *testing.T) {
+	setupTestDownloads(t)
+	stored := "https://cdn.discordapp.com/attachments/1/2/photo.png?ex=aa&is=bb&hm=cc"
+	if err := dbInsertDownload(&downloadItem{URL: stored, Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	records := dbFindDownloadByURL("https://cdn.discordapp.com/attachments/1/2/photo.png?ex=dd&is=ee&hm=ff")
+	if len(records) != 1 {
+		t.Fatalf("found %d records for the re-signed link, want 1", len(records))
+	}
+	if records[0].URL != stored {
+		t.Errorf("stored as %s, want the link as fetched", records[0].URL)
+	}
+}
+
+func TestIsPossibleForward(t *testing.T) {
+	reference := &discordgo.MessageReference{MessageID: "1", ChannelID: "2"}
+	tests := []struct {
+		name    string
+		message discordgo.Message
+		want    bool
+	}{
+		{"forward", discordgo.Message{MessageReference: reference}, true},
+		{"reply", discordgo.Message{Type: discordgo.MessageTypeReply, MessageReference: reference}, false},
+		{"crosspost", discordgo.Message{MessageReference: reference, Flags: discordgo.MessageFlagsIsCrossPosted}, false},
+		{"with content", discordgo.Message{MessageReference: reference, Content: "look"}, false},
+		{"plain", discordgo.Message{}, false},
+	}
+	for _, test := range tests {
+		if got := isPossibleForward(&test.message); got != test.want {
+			t.Errorf("%s: isPossibleForward = %v", test.name, got)
+		}
+	}
+}
+
+// Mirrors are only rewritten to match extractors & find duplicates, the link posted is what's fetched.
+func TestGetParsedLinksKeepsPostedLink(t *testing.T) {
+	source := useTestSource(setupTestDownloads(t))
+	config.URLRewrites = []configurationURLRewrite{{Pattern: `^https://mirror\.test/`, Replace: "https://example.com/"}}
+	m := testDownloadRequest("", source).Message
+
+	items := getParsedLinks("https://mirror.test/photo.png", m)
+	if len(items) != 1 || items[0].Link != "https://mirror.test/photo.png" {
+		t.Fatalf("items = %+v", items)
+	}
+
+	dbInsertDownload(&downloadItem{URL: "https://example.com/photo.png", ChannelID: testChannelID, Time: time.Now()})
+	if items := getParsedLinks("https://mirror.test/photo.png", m); len(items) != 0 {
+		t.Errorf("mirror of a saved link wasn't pruned: %+v", items)
+	}
+}
+
+// Deferred files are only fetched once a worker gets to them, and saved as what they turned into.
+func TestHandleDeferredDownload(t *testing.T) {
+	source := setupTestDownloads(t)
+	text := true
+	source.SaveTextFiles = &text
+	source.Subfolders = &[]string{}
+	source = useTestSource(source)
+
+	stagedPath := filepath.Join(t.TempDir(), "merged.txt")
+	os.WriteFile(stagedPath, []byte("fetched by the worker"), 0644)
+	page := "https://example.com/post/1"
+	resolved := false
+	download := testDownloadRequest(page, source)
+	download.DataKeys = map[string]string{"postID": "1"}
+	download.Resolve = func() ([]*fileItem, error) {
+		resolved = true
+		return []*fileItem{{Link: stageFile(stagedPath, page), Filename: "merged.txt"}}, nil
+	}
+
+	result := queueDownloadsForTest(t, download)
+	if !resolved || result.Status.Status != downloadSuccess {
+		t.Fatalf("status = %s (%v), resolved = %v", getDownloadStatus(result.Status.Status), result.Status.Error, resolved)
+	}
+	if saved, _ := filepath.Glob(filepath.Join(source.Destination, "*merged.txt")); len(saved) != 1 {
+		t.Errorf("deferred file wasn't saved")
+	}
+	if records := dbFindDownloadByURL(page); len(records) != 1 {
+		t.Errorf("found %d records for the page, want 1", len(records))
+	}
+	if _, err := os.Stat(stagedPath); !os.IsNotExist(err) {
+		t.Errorf("staged file left behind")
+	}
+}
+
+func queueDownloadsForTest(t *testing.T, download downloadRequestStruct) downloadJobResult {
+	t.Helper()
+	config.DownloadWorkers = 1
+	startDownloadWorkers()
+	return queueDownloads([]downloadRequestStruct{download})[0]
+}
+
+func TestFindDuplicateContentSourceScope(t *testing.T) {
+	source := setupTestDownloads(t)
+	scope := "source"
+	source.DuplicateContentScope = &scope
+	source.ChannelIDs = &[]string{"101"}
+	source = useTestSource(source)
+	other := configurationSource{ChannelID: "102", Destination: source.Destination}
+	sourceDefault(&other)
+	config.Channels = append(config.Channels, other)
+	for _, id := range []string{"101", "102"} {
+		bot.State.ChannelAdd(&discordgo.Channel{ID: id, Type: discordgo.ChannelTypeDM})
+	}
+
+	saved := filepath.Join(t.TempDir(), "saved.png")
+	os.WriteFile(saved, []byte("content"), 0644)
+	for _, channelID := range []string{"101", "102"} {
+		dbInsertDownload(&downloadItem{URL: "https://example.com/" + channelID, Destination: saved, ChannelID: channelID, Hash: "hash-" + channelID, Time: time.Now()})
+	}
+
+	// Settings reloaded since the download started, every pointer in the source is new
+	current := getSource(&discordgo.Message{ChannelID: testChannelID})
+	reloaded := source
+	reloaded.ChannelIDs = &[]string{"101"}
+	useTestSource(reloaded)
+	config.Channels = append(config.Channels, other)
+	if findDuplicateContent("hash-101", current, testChannelID) == nil {
+		t.Error("file from another channel of the same source wasn't found")
+	}
+	if findDuplicateContent("hash-102", current, testChannelID) != nil {
+		t.Error("file from another source was found")
+	}
+}
+
+// Hardlinking makes a file, which a source that isn't saving shouldn't end up with.
+func TestDuplicateContentHardlinkNeedsSave(t *testing.T) {
+	source := setupTestDownloads(t)
+	save, text, mode := false, true, "hardlink"
+	source.Save = &save
+	source.SaveTextFiles = &text
+	source.DuplicateContent = &mode
+	source.Subfolders = &[]string{}
+	source = useTestSource(source)
+
+	content := []byte("the same content twice")
+	saved := filepath.Join(t.TempDir(), "saved.txt")
+	os.WriteFile(saved, content, 0644)
+	hash := sha256.Sum256(content)
+	dbInsertDownload(&downloadItem{URL: "https://example.com/first.txt", Destination: saved, Hash: hex.EncodeToString(hash[:]), Time: time.Now()})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+	download := testDownloadRequest(server.URL+"/second.txt", source)
+	download.Filename = "second.txt"
+	if status, _ := download.tryDownload(); status.Status == downloadSkippedDuplicateContentLinked {
+		t.Error("linked a file for a source that isn't saving")
+	}
+	if entries, _ := os.ReadDir(source.Destination); len(entries) != 0 {
+		t.Errorf("found %d files in the destination", len(entries))
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Bluesky posts through the public AppView. The post record holds the blob references, originals
+// come from the author's PDS, the CDN's fullsize copy being the fallback for images.
+
+var regexUrlBlueskyPost = regexp.MustCompile(`^https?:\/\/(www\.)?bsky\.app\/profile\/([^\/]+)\/post\/([0-9a-zA-Z]+)`)
+
+const (
+	blueskyAppView = "https://public.api.bsky.app"
+	blueskyCDN     = "https://cdn.bsky.app/img/feed_fullsize/plain"
+	blueskyGIFHost = "media.tenor.com" // the GIF picker posts Tenor links as external embeds
+)
+
+var (
+	blueskyPDSMutex sync.Mutex
+	blueskyPDSCache = map[string]string{} // DID to PDS endpoint
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name:   "bluesky",
+		routes: []extractorRoute{{regexUrlBlueskyPost, getBlueskyPostFiles}},
+		ignoreError: func(err error) bool {
+			return strings.Contains(err.Error(), "NotFound")
+		},
+	})
+}
+
+type blueskyThread struct {
+	Thread struct {
+		Post struct {
+			URI    string `json:"uri"`
+			Author struct {
+				DID    string `json:"did"`
+				Handle string `json:"handle"`
+			} `json:"author"`
+			Record struct {
+				Embed *blueskyEmbed `json:"embed"`
+			} `json:"record"`
+		} `json:"post"`
+	} `json:"thread"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type blueskyEmbed struct {
+	Images []struct {
+		Image blueskyBlob `json:"image"`
+	} `json:"images"`
+	Video    *blueskyBlob `json:"video"`
+	External *struct {
+		URI string `json:"uri"`
+	} `json:"external"`
+	Media *blueskyEmbed `json:"media"` // recordWithMedia, a quote post with media of its own
+}
+
+type blueskyBlob struct {
+	Ref struct {
+		Link string `json:"$link"`
+	} `json:"ref"`
+	MimeType string `json:"mimeType"`
+}
+
+func getBlueskyPostFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	matches := regexUrlBlueskyPost.FindStringSubmatch(link)
+	actor, postID := matches[2], matches[3]
+
+	ctx := requestContext(m)
+	did := actor
+	if !strings.HasPrefix(actor, "did:") {
+		var resolved struct {
+			DID string `json:"did"`
+		}
+		if err := getJSON(ctx, blueskyAppView+"/xrpc/com.atproto.identity.resolveHandle?handle="+url.QueryEscape(actor), &resolved); err != nil {
+			return nil, fmt.Errorf("failed to resolve bluesky handle %s:\t%s", actor, err)
+		}
+		if resolved.DID == "" {
+			return nil, fmt.Errorf("bluesky handle %s not found", actor)
+		}
+		did = resolved.DID
+	}
+
+	thread := new(blueskyThread)
+	postURI := "at://" + did + "/app.bsky.feed.post/" + postID
+	if err := getJSON(ctx, blueskyAppView+"/xrpc/app.bsky.feed.getPostThread?depth=0&parentHeight=0&uri="+url.QueryEscape(postURI), thread); err != nil {
+		return nil, fmt.Errorf("failed to parse json from bluesky post:\t%s", err)
+	}
+	if thread.Error != "" {
+		return nil, fmt.Errorf("%s: %s", thread.Error, thread.Message)
+	}
+	post := thread.Thread.Post
+	embed := post.Record.Embed
+	if embed != nil && embed.Media != nil {
+		embed = embed.Media
+	}
+	if embed == nil {
+		return nil, nil
+	}
+
+	prefix := fmt.Sprintf("Bluesky-%s_%s", post.Author.Handle, postID)
+	pds := getBlueskyPDS(ctx, did)
+	blobLink := func(blob blueskyBlob) string {
+		if pds != "" {
+			return pds + "/xrpc/com.atproto.sync.getBlob?did=" + url.QueryEscape(did) + "&cid=" + url.QueryEscape(blob.Ref.Link)
+		}
+		if strings.HasPrefix(blob.MimeType, "image/") {
+			return blueskyCDN + "/" + did + "/" + blob.Ref.Link + "@jpeg"
+		}
+		return "" // videos are only on the CDN as HLS playlists
+	}
+
+	var media []*fileItem
+	for _, image := range embed.Images {
+		if link := blobLink(image.Image); link != "" {
+			media = append(media, &fileItem{Link: link, Filename: prefix + " " + image.Image.Ref.Link + mimeExtension(image.Image.MimeType)})
+		}
+	}
+	if embed.Video != nil {
+		if link := blobLink(*embed.Video); link != "" {
+			media = append(media, &fileItem{Link: link, Filename: prefix + " " + embed.Video.Ref.Link + mimeExtension(embed.Video.MimeType)})
+		} else {
+			return nil, errors.New("couldn't find the PDS holding the bluesky video")
+		}
+	}
+	if embed.External != nil {
+		if parsed, err := url.Parse(embed.External.URI); err == nil && parsed.Hostname() == blueskyGIFHost {
+			media = append(media, &fileItem{Link: embed.External.URI, Filename: prefix + " " + filenameFromURL(embed.External.URI)})
+		}
+	}
+
+	return expandPostMedia(media, map[string]string{
+		"blueskyAuthor": post.Author.Handle,
+		"blueskyPostID": postID,
+	}, m), nil
+}
+
+// The PDS endpoint from the DID document, empty when it can't be found.
+func getBlueskyPDS(ctx context.Context, did string) string {
+	blueskyPDSMutex.Lock()
+	pds, cached := blueskyPDSCache[did]
+	blueskyPDSMutex.Unlock()
+	if cached {
+		return pds
+	}
+
+	var documentURL string
+	switch {
+	case strings.HasPrefix(did, "did:plc:"):
+		documentURL = "https://plc.directory/" + did
+	case strings.HasPrefix(did, "did:web:"):
+		documentURL = "https://" + strings.TrimPrefix(did, "did:web:") + "/.well-known/did.json"
+	default:
+		return ""
+	}
+	var document struct {
+		Service []struct {
+			ID              string `json:"id"`
+			ServiceEndpoint string `json:"serviceEndpoint"`
+		} `json:"service"`
+	}
+	if err := getJSON(ctx, documentURL, &document); err != nil {
+		return "" // not cached, may just be down
+	}
+	for _, service := range document.Service {
+		if service.ID == "#atproto_pds" {
+			pds = strings.TrimSuffix(service.ServiceEndpoint, "/")
+		}
+	}
+	blueskyPDSMutex.Lock()
+	blueskyPDSCache[did] = pds
+	blueskyPDSMutex.Unlock()
+	return pds
+}
+
+// File extension for a blob's mime type, with the dot.
+func mimeExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "video/quicktime":
+		return ".mov"
+	case "":
+		return ""
+	}
+	if index := strings.Index(mimeType, "/"); index != -1 {
+		return "." + strings.TrimPrefix(mimeType[index+1:], "x-")
+	}
+	return ""
+}
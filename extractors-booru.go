@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Image boards on the Danbooru, Gelbooru (0.2 & Safebooru's 0.1) and Moebooru engines. Each post
+// gives the original file along with its tags, exposed as {{artist}}, {{tags}}, {{rating}} & {{booruPostID}}.
+
+var (
+	regexUrlDanbooruPost = regexp.MustCompile(`^https?:\/\/(danbooru|safebooru|testbooru)\.donmai\.us\/posts\/([0-9]+)`)
+	regexUrlGelbooruPost = regexp.MustCompile(`^https?:\/\/(www\.)?(gelbooru\.com|safebooru\.org)\/index\.php\?(.+&)?page=post(&.+)?$`)
+	regexUrlMoebooruPost = regexp.MustCompile(`^https?:\/\/(yande\.re|konachan\.(com|net))\/post\/show\/([0-9]+)`)
+)
+
+// Plenty of posts have a hundred tags or more, too many for a path
+const booruTagsMaxLength = 120
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "booru",
+		routes: []extractorRoute{
+			{regexUrlDanbooruPost, getDanbooruPostFiles},
+			{regexUrlGelbooruPost, getGelbooruPostFiles},
+			{regexUrlMoebooruPost, getMoebooruPostFiles},
+		},
+	})
+}
+
+type booruPost struct {
+	Site    string
+	ID      string
+	FileURL string
+	Tags    []string
+	Artists []string
+	Rating  string
+}
+
+func (post booruPost) fileItems() []*fileItem {
+	tags := ""
+	for _, tag := range post.Tags {
+		if stringInSlice(tag, post.Artists) {
+			continue
+		}
+		if len(tags)+len(tag)+1 > booruTagsMaxLength {
+			break
+		}
+		tags = strings.TrimSpace(tags + " " + tag)
+	}
+	return []*fileItem{{
+		Link:     post.FileURL,
+		Filename: fmt.Sprintf("%s-%s %s", post.Site, post.ID, filenameFromURL(post.FileURL)),
+		DataKeys: map[string]string{
+			"artist":      strings.Join(post.Artists, ", "),
+			"tags":        tags,
+			"rating":      post.Rating,
+			"booruPostID": post.ID,
+		},
+	}}
+}
+
+// Ratings are single letters on some engines, full words on others
+func booruRating(rating string) string {
+	switch rating {
+	case "g":
+		return "general"
+	case "s":
+		return "sensitive"
+	case "q":
+		return "questionable"
+	case "e":
+		return "explicit"
+	}
+	return rating
+}
+
+//#region Danbooru
+
+func getDanbooruPostFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	matches := regexUrlDanbooruPost.FindStringSubmatch(link)
+	host, postID := matches[1]+".donmai.us", matches[2]
+
+	// Basic auth keeps the key out of the URL, which ends up in errors
+	apiURL := "https://" + host + "/posts/" + postID + ".json"
+	headers := map[string]string{}
+	if config.Credentials.DanbooruUsername != "" && config.Credentials.DanbooruApiKey != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(config.Credentials.DanbooruUsername+":"+config.Credentials.DanbooruApiKey))
+	}
+	var post struct {
+		FileURL         string `json:"file_url"`
+		TagString       string `json:"tag_string"`
+		TagStringArtist string `json:"tag_string_artist"`
+		Rating          string `json:"rating"`
+	}
+	if err := getJSONwithHeaders(requestContext(m), apiURL, &post, headers); err != nil {
+		return nil, fmt.Errorf("failed to parse json from danbooru post:\t%s", err)
+	}
+	if post.FileURL == "" {
+		return nil, errors.New("danbooru post has no file, it may need a login with a higher account level")
+	}
+
+	site := "Danbooru"
+	if matches[1] == "safebooru" {
+		site = "Safebooru"
+	}
+	return booruPost{
+		Site:    site,
+		ID:      postID,
+		FileURL: post.FileURL,
+		Tags:    strings.Fields(post.TagString),
+		Artists: strings.Fields(post.TagStringArtist),
+		Rating:  booruRating(post.Rating),
+	}.fileItems(), nil
+}
+
+//#endregion
+
+//#region Gelbooru
+
+type gelbooruPost struct {
+	FileURL   string `json:"file_url"`
+	Directory string `json:"directory"`
+	Image     string `json:"image"`
+	Tags      string `json:"tags"`
+	Rating    string `json:"rating"`
+}
+
+func getGelbooruPostFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	postID := parsed.Query().Get("id")
+	if postID == "" {
+		return nil, nil // a search or listing, not a post
+	}
+	host := strings.TrimPrefix(parsed.Hostname(), "www.")
+
+	apiURL := "https://" + host + "/index.php?page=dapi&s=post&q=index&json=1&id=" + url.QueryEscape(postID)
+	if host == "gelbooru.com" && config.Credentials.GelbooruUserID != "" && config.Credentials.GelbooruApiKey != "" {
+		apiURL += "&user_id=" + url.QueryEscape(config.Credentials.GelbooruUserID) +
+			"&api_key=" + url.QueryEscape(config.Credentials.GelbooruApiKey)
+	}
+
+	// Gelbooru wraps the list in an object, Safebooru's older engine doesn't
+	ctx := requestContext(m)
+	var posts []gelbooruPost
+	if host == "gelbooru.com" {
+		var response struct {
+			Post []gelbooruPost `json:"post"`
+		}
+		err = getJSON(ctx, apiURL, &response)
+		posts = response.Post
+	} else {
+		err = getJSON(ctx, apiURL, &posts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json from %s post:\t%s", host, err)
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("%s post %s not found", host, postID)
+	}
+
+	post := posts[0]
+	if post.FileURL == "" && post.Directory != "" && post.Image != "" {
+		post.FileURL = "https://" + host + "/images/" + post.Directory + "/" + post.Image
+	}
+	if post.FileURL == "" {
+		return nil, errors.New("post has no file url")
+	}
+	tags := strings.Fields(post.Tags)
+
+	site := "Gelbooru"
+	if host == "safebooru.org" {
+		site = "Safebooru"
+	}
+	return booruPost{
+		Site:    site,
+		ID:      postID,
+		FileURL: post.FileURL,
+		Tags:    tags,
+		Artists: getGelbooruArtists(ctx, host, tags),
+		Rating:  booruRating(post.Rating),
+	}.fileItems(), nil
+}
+
+// Posts don't say which tags are artists, the tag API does. Only Gelbooru answers in JSON.
+func getGelbooruArtists(ctx context.Context, host string, tags []string) []string {
+	if host != "gelbooru.com" || len(tags) == 0 {
+		return nil
+	}
+	apiURL := "https://" + host + "/index.php?page=dapi&s=tag&q=index&json=1&names=" + url.QueryEscape(strings.Join(tags, " "))
+	if config.Credentials.GelbooruUserID != "" && config.Credentials.GelbooruApiKey != "" {
+		apiURL += "&user_id=" + url.QueryEscape(config.Credentials.GelbooruUserID) +
+			"&api_key=" + url.QueryEscape(config.Credentials.GelbooruApiKey)
+	}
+	var response struct {
+		Tag []struct {
+			Name string `json:"name"`
+			Type int    `json:"type"`
+		} `json:"tag"`
+	}
+	if getJSON(ctx, apiURL, &response) != nil {
+		return nil
+	}
+	var artists []string
+	for _, tag := range response.Tag {
+		if tag.Type == 1 {
+			artists = append(artists, tag.Name)
+		}
+	}
+	return artists
+}
+
+//#endregion
+
+//#region Moebooru
+
+func getMoebooruPostFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	matches := regexUrlMoebooruPost.FindStringSubmatch(link)
+	host, postID := matches[1], matches[3]
+
+	// The second API version lists every tag's type alongside the posts
+	var response struct {
+		Posts []struct {
+			FileURL string `json:"file_url"`
+			Tags    string `json:"tags"`
+			Rating  string `json:"rating"`
+		} `json:"posts"`
+		Tags map[string]string `json:"tags"`
+	}
+	apiURL := "https://" + host + "/post.json?api_version=2&include_tags=1&tags=id:" + postID
+	if err := getJSON(requestContext(m), apiURL, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse json from %s post:\t%s", host, err)
+	}
+	if len(response.Posts) == 0 || response.Posts[0].FileURL == "" {
+		return nil, fmt.Errorf("%s post %s not found", host, postID)
+	}
+	post := response.Posts[0]
+
+	tags := strings.Fields(post.Tags)
+	var artists []string
+	for _, tag := range tags {
+		if response.Tags[tag] == "artist" {
+			artists = append(artists, tag)
+		}
+	}
+
+	site := "Yande.re"
+	if host != "yande.re" {
+		site = "Konachan"
+	}
+	rating := booruRating(post.Rating)
+	if rating == "sensitive" { // Moebooru's s is safe
+		rating = "safe"
+	}
+	return booruPost{
+		Site:    site,
+		ID:      postID,
+		FileURL: post.FileURL,
+		Tags:    tags,
+		Artists: artists,
+		Rating:  rating,
+	}.fileItems(), nil
+}
+
+//#endregion
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBooruRating(t *testing.T) {
+	tests := map[string]string{
+		"g":        "general",
+		"s":        "sensitive",
+		"q":        "questionable",
+		"e":        "explicit",
+		"explicit": "explicit",
+		"":         "",
+	}
+	for rating, want := range tests {
+		if got := booruRating(rating); got != want {
+			t.Errorf("booruRating(%q) = %q, want %q", rating, got, want)
+		}
+	}
+}
+
+func TestBooruPostFileItems(t *testing.T) {
+	var tags []string
+	for i := 0; i < 50; i++ {
+		tags = append(tags, "tag_number_"+strings.Repeat("x", i%5))
+	}
+	post := booruPost{
+		Site:    "Danbooru",
+		ID:      "123",
+		FileURL: "https://cdn.donmai.us/original/ab/cd/abcd.png",
+		Tags:    append([]string{"some_artist"}, tags...),
+		Artists: []string{"some_artist"},
+		Rating:  "general",
+	}
+	items := post.fileItems()
+	if len(items) != 1 {
+		t.Fatalf("%d items", len(items))
+	}
+	item := items[0]
+	if item.Filename != "Danbooru-123 abcd.png" {
+		t.Errorf("filename = %q", item.Filename)
+	}
+	if item.DataKeys["artist"] != "some_artist" || item.DataKeys["booruPostID"] != "123" || item.DataKeys["rating"] != "general" {
+		t.Errorf("keys = %v", item.DataKeys)
+	}
+	if keyTags := item.DataKeys["tags"]; len(keyTags) > booruTagsMaxLength || strings.Contains(keyTags, "some_artist") ||
+		!strings.HasPrefix(keyTags, "tag_number_ ") {
+		t.Errorf("tags = %q", keyTags)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fatih/color"
+)
+
+// Links to other Discord messages bring in that message's files, as long as the bot can see it.
+// They're saved as part of the message with the link, the linked message's ID kept as the origin.
+
+var regexUrlDiscordMessage = regexp.MustCompile(`^https?:\/\/((www|ptb|canary)\.)?discord(app)?\.com\/channels\/([0-9]+|@me)\/([0-9]+)\/([0-9]+)\/?$`)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name:        "discord",
+		routes:      []extractorRoute{{regexUrlDiscordMessage, getDiscordMessageFiles}},
+		ignoreError: ignoreDiscordMessageError,
+	})
+}
+
+// Nothing to be done about messages the bot can't see
+func ignoreDiscordMessageError(err error) bool {
+	return strings.Contains(err.Error(), "Missing Access") || strings.Contains(err.Error(), "Unknown Message") ||
+		strings.Contains(err.Error(), "Unknown Channel")
+}
+
+// Links reaching the extractors are from top level messages, deeper ones come through getNestedDiscordMessageFiles.
+func getDiscordMessageFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	return getLinkedMessageFiles(link, m, 1)
+}
+
+// getNestedDiscordMessageFiles does what running the extractors would for a link found depth links deep.
+func getNestedDiscordMessageFiles(link string, m *discordgo.Message, depth int) []*fileItem {
+	items, err := getLinkedMessageFiles(link, m, depth)
+	if err != nil && ignoreDiscordMessageError(err) {
+		err = nil
+	} else if err != nil {
+		err = errors.New(redactURLQueries(err.Error()))
+	}
+	recordExtractorResult("discord", len(items), err)
+	if err != nil {
+		log.Println(lg("Download", "", color.RedString, "discord extraction failed for %s -- %s", link, err))
+		return nil
+	}
+	return pruneCompletedLinks(items, m)
+}
+
+// getLinkedMessageFiles gets the files in a linked message, depth being how many links deep it is.
+func getLinkedMessageFiles(link string, m *discordgo.Message, depth int) ([]*fileItem, error) {
+	if depth > config.MessageLinkDepth {
+		return nil, nil
+	}
+
+	matches := regexUrlDiscordMessage.FindStringSubmatch(link)
+	channelID, messageID := matches[5], matches[6]
+	if messageID == m.ID {
+		return nil, nil
+	}
+	linked, err := bot.State.Message(channelID, messageID)
+	if err != nil {
+		if linked, err = bot.ChannelMessage(channelID, messageID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Looked through as if it were in the linking channel, so the files are pruned & filtered by its settings
+	relocated := *linked
+	relocated.ChannelID = m.ChannelID
+	relocated.GuildID = m.GuildID
+
+	items := getLinksByMessageFrom(&relocated, linked.ChannelID, depth)
+
+	for _, item := range items {
+		// Deeper links already point at the message that actually had the file
+		if item.OriginMessageID == "" {
+			item.OriginMessageID = linked.ID
+			item.OriginType = "link"
+		}
+	}
+	return items, nil
+}
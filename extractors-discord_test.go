@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestLinkedMessageDepth(t *testing.T) {
+	useTestSource(setupTestDownloads(t))
+	bot.State.MaxMessageCount = 10
+	messageLink := "https://discord.com/channels/@me/" + testChannelID + "/"
+	attachment := func(name string) []*discordgo.MessageAttachment {
+		return []*discordgo.MessageAttachment{{ID: name, Filename: name,
+			URL: "https://cdn.discordapp.com/attachments/" + testChannelID + "/1/" + name}}
+	}
+	messages := []*discordgo.Message{
+		{ID: "301", Content: messageLink + "302"},
+		{ID: "302", Content: messageLink + "303", Attachments: attachment("b.png")},
+		{ID: "303", Attachments: attachment("c.png")},
+	}
+	for _, message := range messages {
+		message.ChannelID = testChannelID
+		message.Author = &discordgo.User{ID: "2"}
+		bot.State.MessageAdd(message)
+	}
+	filenames := func(m *discordgo.Message) []string {
+		var names []string
+		for _, item := range getLinksByMessage(m) {
+			names = append(names, item.Filename)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	config.MessageLinkDepth = 1
+	if got := filenames(messages[0]); len(got) != 1 || got[0] != "b.png" {
+		t.Errorf("one link deep: %v", got)
+	}
+	// The linked message on its own is a level up, its links are followed as usual
+	if got := filenames(messages[1]); len(got) != 2 || got[0] != "b.png" || got[1] != "c.png" {
+		t.Errorf("linked message on its own: %v", got)
+	}
+	config.MessageLinkDepth = 2
+	if got := filenames(messages[0]); len(got) != 2 || got[1] != "c.png" {
+		t.Errorf("two links deep: %v", got)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Hands links on listed domains to a locally installed yt-dlp or gallery-dl. In "urls" mode the tool
+// only resolves media URLs for us to download, in "direct" mode it downloads the files itself into
+// a temporary folder by the download worker and they're staged through tryDownload from there.
+
+const (
+	externalToolYtdlp     = "yt-dlp"
+	externalToolGalleryDl = "gallery-dl"
+
+	externalModeURLs   = "urls"
+	externalModeDirect = "direct"
+
+	externalTimeoutDefault = 120 // seconds
+	externalStderrMax      = 500 // characters of stderr kept for errors
+)
+
+type externalExtractor struct {
+	settings configurationExternalExtractor
+}
+
+// Built from settings on every lookup, so they follow settings reloads.
+func getExternalExtractors() []Extractor {
+	var list []Extractor
+	for _, settings := range config.ExternalExtractors {
+		list = append(list, &externalExtractor{settings})
+	}
+	return list
+}
+
+func (extractor *externalExtractor) Name() string {
+	if extractor.settings.Name != "" {
+		return extractor.settings.Name
+	}
+	return extractor.settings.Tool
+}
+
+// Match only goes by the domain allowlist, nothing is handed out without one. The tools take
+// other schemes too (file:// and such), only web links are ever passed on.
+func (extractor *externalExtractor) Match(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return hostInDomains(parsed.Hostname(), extractor.settings.Domains)
+}
+
+func (extractor *externalExtractor) NeedsAuth() bool {
+	return false
+}
+
+func (extractor *externalExtractor) Extract(link string, m *discordgo.Message) ([]*fileItem, error) {
+	settings := extractor.settings
+	if settings.Tool != externalToolYtdlp && settings.Tool != externalToolGalleryDl {
+		return nil, fmt.Errorf("unknown tool \"%s\", use %s or %s", settings.Tool, externalToolYtdlp, externalToolGalleryDl)
+	}
+
+	switch settings.Mode {
+	case "", externalModeURLs:
+		links, err := extractor.resolve(link, m)
+		return fileItemsFromLinks(links), err
+	case externalModeDirect:
+		// Left to the worker, and pruned like any other link before it gets there
+		return []*fileItem{{Link: link, Resolve: func() ([]*fileItem, error) {
+			links, err := extractor.download(link, m)
+			return fileItemsFromLinks(links), err
+		}}}, nil
+	}
+	return nil, fmt.Errorf("unknown mode \"%s\", use %s or %s", settings.Mode, externalModeURLs, externalModeDirect)
+}
+
+// run calls the tool with args after the ones from settings, through m's proxy like our own requests.
+func (extractor *externalExtractor) run(m *discordgo.Message, args ...string) ([]byte, error) {
+	settings := extractor.settings
+	if proxy := getSourceProxy(m); strings.EqualFold(proxy, "direct") {
+		if settings.Tool == externalToolYtdlp {
+			args = append([]string{"--proxy", ""}, args...) // empty for none, gallery-dl has no such option
+		}
+	} else if proxy != "" {
+		args = append([]string{"--proxy", proxy}, args...)
+	}
+	binary := settings.Path
+	if binary == "" {
+		binary = settings.Tool
+	}
+	timeout := settings.Timeout
+	if timeout <= 0 {
+		timeout = externalTimeoutDefault
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, append(settings.Args, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %ds", settings.Tool, timeout)
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			lines := strings.Split(output, "\n")
+			return nil, fmt.Errorf("%s: %s", err, truncateString(lines[len(lines)-1], externalStderrMax))
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// Only asks the tool for the media URLs & names.
+func (extractor *externalExtractor) resolve(link string, m *discordgo.Message) (map[string]string, error) {
+	links := make(map[string]string)
+	switch extractor.settings.Tool {
+	case externalToolYtdlp:
+		args := []string{"--dump-json", "--no-warnings", "--no-progress"}
+		if !stringInSlice("-f", extractor.settings.Args) && !stringInSlice("--format", extractor.settings.Args) {
+			args = append(args, "-f", "b") // single file with audio & video, there's nothing here to merge them
+		}
+		output, err := extractor.run(m, append(args, "--", link)...)
+		if err != nil {
+			return nil, err
+		}
+		// One object per line, more than one for playlists
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var info struct {
+				URL      string `json:"url"`
+				ID       string `json:"id"`
+				Title    string `json:"title"`
+				Ext      string `json:"ext"`
+				Filename string `json:"filename"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &info); err != nil || info.URL == "" {
+				continue
+			}
+			filename := filepath.Base(info.Filename)
+			if info.Filename == "" {
+				filename = fmt.Sprintf("%s [%s].%s", info.Title, info.ID, info.Ext)
+			}
+			links[info.URL] = filename
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+	case externalToolGalleryDl:
+		output, err := extractor.run(m, "--dump-json", "--", link)
+		if err != nil {
+			return nil, err
+		}
+		// A list of messages, [3, url, metadata] being the files
+		var messages []json.RawMessage
+		if err := json.Unmarshal(output, &messages); err != nil {
+			return nil, fmt.Errorf("unreadable gallery-dl output: %s", err)
+		}
+		for _, raw := range messages {
+			var message []json.RawMessage
+			if json.Unmarshal(raw, &message) != nil || len(message) < 3 {
+				continue
+			}
+			var kind int
+			var fileURL string
+			var metadata struct {
+				Filename  string `json:"filename"`
+				Extension string `json:"extension"`
+			}
+			if json.Unmarshal(message[0], &kind) != nil || kind != 3 || json.Unmarshal(message[1], &fileURL) != nil {
+				continue
+			}
+			json.Unmarshal(message[2], &metadata)
+			filename := ""
+			if metadata.Filename != "" {
+				filename = metadata.Filename + "." + metadata.Extension
+			}
+			links[fileURL] = filename
+		}
+	}
+	if len(links) == 0 {
+		return nil, errors.New("no media found")
+	}
+	return links, nil
+}
+
+// Lets the tool download everything into a temporary folder, then stages what it left there.
+func (extractor *externalExtractor) download(link string, m *discordgo.Message) (map[string]string, error) {
+	dir, err := os.MkdirTemp("", "ddg-"+extractor.settings.Tool+"-")
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	switch extractor.settings.Tool {
+	case externalToolYtdlp:
+		args = []string{"--no-warnings", "--no-progress", "-o", filepath.Join(dir, "%(title)s [%(id)s].%(ext)s"), "--", link}
+	case externalToolGalleryDl:
+		args = []string{"--directory", dir, "--", link}
+	}
+	if _, err := extractor.run(m, args...); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	links := make(map[string]string)
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) { // leftovers from unfinished or merged downloads
+		case ".part", ".ytdl", ".temp":
+			os.Remove(path)
+			return nil
+		}
+		links[stageFile(path, link)] = ""
+		return nil
+	})
+	if len(links) == 0 {
+		os.RemoveAll(dir)
+		return nil, errors.New("no files downloaded")
+	}
+	return links, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestExternalExtractorMatch(t *testing.T) {
+	config = defaultConfiguration()
+	extractor := &externalExtractor{configurationExternalExtractor{Tool: externalToolYtdlp, Domains: []string{"example.com"}}}
+	tests := map[string]bool{
+		"https://example.com/watch?v=1":   true,
+		"http://www.example.com/watch":    true,
+		"https://other.test/watch":        false,
+		"file://example.com/etc/passwd":   false,
+		"ytsearch:example.com":            false,
+		"-o/tmp/x https://example.com/v":  false,
+		"ftp://example.com/pub/video.mp4": false,
+	}
+	for link, want := range tests {
+		if got := extractor.Match(link); got != want {
+			t.Errorf("Match(%q) = %v, want %v", link, got, want)
+		}
+	}
+}
+
+// Links go after --, so one that looks like an option can't be taken for one.
+func TestExternalExtractorEndsOptions(t *testing.T) {
+	source := useTestSource(setupTestDownloads(t))
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	tool := filepath.Join(dir, "yt-dlp")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsPath + "\n" +
+		`echo '{"url":"https://cdn.example.com/v.mp4","id":"1","title":"video","ext":"mp4"}'` + "\n"
+	if err := os.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	extractor := &externalExtractor{configurationExternalExtractor{Tool: externalToolYtdlp, Path: tool, Domains: []string{"example.com"}}}
+	m := &discordgo.Message{ID: "200", ChannelID: source.ChannelID}
+	items, err := extractor.Extract("https://example.com/watch?v=1", m)
+	if err != nil || len(items) != 1 || items[0].Link != "https://cdn.example.com/v.mp4" {
+		t.Fatalf("items = %+v, %v", items, err)
+	}
+	args, _ := os.ReadFile(argsPath)
+	if !strings.HasSuffix(string(args), "\n--\nhttps://example.com/watch?v=1\n") {
+		t.Errorf("arguments =\n%s", args)
+	}
+}
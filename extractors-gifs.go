@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// GIF sites, whose Discord embeds only carry a small preview video. Pages & media links are turned
+// into the original GIF, or the MP4 when the source's gifFormat asks for it.
+
+var (
+	regexUrlTenorPage  = regexp.MustCompile(`^https?:\/\/(www\.)?tenor\.com\/([a-z]{2}(-[A-Za-z]{2})?\/)?view\/[^/?#]+-[0-9]+\/?(\?.*)?$`)
+	regexUrlTenorMedia = regexp.MustCompile(`^https?:\/\/(media[0-9]*|c)\.tenor\.com\/(m\/)?([A-Za-z0-9_-]{11})([A-Za-z0-9_-]{5})\/([^/?#]+)\.([a-z0-9]+)(\?.*)?$`)
+
+	regexUrlGiphyPage  = regexp.MustCompile(`^https?:\/\/(www\.)?giphy\.com\/(gifs|stickers|embed)\/([^/?#]+)\/?(\?.*)?$`)
+	regexUrlGiphyMedia = regexp.MustCompile(`^https?:\/\/(media[0-9]*|i)\.giphy\.com\/(media\/(v1\.[^/]+\/)?([A-Za-z0-9]+)\/[^/?#]+|([A-Za-z0-9]+))\.(gif|mp4|webp)(\?.*)?$`)
+)
+
+// Tenor media URLs end the media ID with a code for the format
+var tenorFormatCodes = map[string]string{
+	"gif": "AAAAC",
+	"mp4": "AAAPo",
+}
+
+const giphyHDTimeout = 15 * time.Second
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "tenor",
+		routes: []extractorRoute{
+			{regexUrlTenorPage, getTenorPageFiles},
+			{regexUrlTenorMedia, getTenorMediaFiles},
+		},
+	})
+	registerExtractor(&siteExtractor{
+		name: "giphy",
+		routes: []extractorRoute{
+			{regexUrlGiphyPage, getGiphyFiles},
+			{regexUrlGiphyMedia, getGiphyFiles},
+		},
+	})
+}
+
+// isGIFProviderPage says whether an embed's media can be left for the extractors to replace.
+func isGIFProviderPage(link string) bool {
+	return (regexUrlTenorPage.MatchString(link) && extractorEnabled("tenor")) ||
+		(regexUrlGiphyPage.MatchString(link) && extractorEnabled("giphy"))
+}
+
+func getGIFFormat(m *discordgo.Message) string {
+	format := config.GIFFormat
+	if m != nil {
+		if sourceConfig := getSource(m); sourceConfig.GIFFormat != nil {
+			format = *sourceConfig.GIFFormat
+		}
+	}
+	if strings.EqualFold(format, "mp4") {
+		return "mp4"
+	}
+	return "gif"
+}
+
+//#region Tenor
+
+// Pages don't have an API without a key, but their preview tags link media of the same ID.
+func getTenorPageFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	candidates, err := getPageMetaCandidates(requestContext(m), link)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		if regexUrlTenorMedia.MatchString(candidate.URL) {
+			return getTenorMediaFiles(candidate.URL, m)
+		}
+	}
+	return nil, nil
+}
+
+func getTenorMediaFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	matches := regexUrlTenorMedia.FindStringSubmatch(link)
+	format := getGIFFormat(m)
+	if matches[4] == tenorFormatCodes[format] && matches[6] == format {
+		return nil, nil // already what we're after, leave it to the direct download
+	}
+	original := "https://" + matches[1] + ".tenor.com/" + matches[2] + matches[3] + tenorFormatCodes[format] +
+		"/" + matches[5] + "." + format
+	return []*fileItem{{Link: original, Filename: matches[5] + "." + format}}, nil
+}
+
+//#endregion
+
+//#region Giphy
+
+// Every rendition lives under the GIF's ID, giphy.gif & giphy.mp4 being the originals.
+func getGiphyFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	giphyID, name := "", ""
+	if matches := regexUrlGiphyPage.FindStringSubmatch(link); matches != nil {
+		// Slugs are the title then the ID, or only the ID
+		name = matches[3]
+		giphyID = name[strings.LastIndex(name, "-")+1:]
+	} else if matches := regexUrlGiphyMedia.FindStringSubmatch(link); matches != nil {
+		giphyID = matches[4] + matches[5]
+		name = giphyID
+	}
+	if giphyID == "" {
+		return nil, nil
+	}
+
+	base := "https://media.giphy.com/media/" + giphyID + "/"
+	if getGIFFormat(m) == "gif" {
+		return []*fileItem{{Link: base + "giphy.gif", Filename: name + ".gif"}}, nil
+	}
+	// HD is only there for some uploads
+	if giphyMediaExists(requestContext(m), base+"giphy-hd.mp4") {
+		return []*fileItem{{Link: base + "giphy-hd.mp4", Filename: name + ".mp4"}}, nil
+	}
+	return []*fileItem{{Link: base + "giphy.mp4", Filename: name + ".mp4"}}, nil
+}
+
+func giphyMediaExists(ctx context.Context, link string) bool {
+	request, err := http.NewRequestWithContext(withRequestTimeout(ctx, giphyHDTimeout), "HEAD", link, nil)
+	if err != nil {
+		return false
+	}
+	response, err := doRequest(request)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == http.StatusOK
+}
+
+//#endregion
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTenorMediaFiles(t *testing.T) {
+	config = defaultConfiguration()
+	tests := []struct {
+		link, format, want string
+	}{
+		{"https://media.tenor.com/abcdefghijkAAAPo/cat-dance.mp4", "gif", "https://media.tenor.com/abcdefghijkAAAAC/cat-dance.gif"},
+		{"https://media1.tenor.com/m/abcdefghijkAAAAd/cat-dance.gif?width=220", "gif", "https://media1.tenor.com/m/abcdefghijkAAAAC/cat-dance.gif"},
+		{"https://c.tenor.com/abcdefghijkAAAAC/cat-dance.gif", "mp4", "https://c.tenor.com/abcdefghijkAAAPo/cat-dance.mp4"},
+		{"https://media.tenor.com/abcdefghijkAAAAC/cat-dance.gif", "gif", ""},
+	}
+	for _, test := range tests {
+		config.GIFFormat = test.format
+		files, err := getTenorMediaFiles(test.link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.want == "" {
+			if len(files) != 0 {
+				t.Errorf("%s (%s) rewritten to itself: %s", test.link, test.format, files[0].Link)
+			}
+			continue
+		}
+		if len(files) != 1 || files[0].Link != test.want || files[0].Filename != "cat-dance."+test.format {
+			t.Errorf("%s (%s) = %+v, want %s", test.link, test.format, files, test.want)
+		}
+	}
+}
+
+func TestGiphyFiles(t *testing.T) {
+	config = defaultConfiguration()
+	tests := map[string]string{
+		"https://giphy.com/gifs/cat-dance-AbC123xyz":                  "cat-dance-AbC123xyz.gif",
+		"https://giphy.com/embed/AbC123xyz":                           "AbC123xyz.gif",
+		"https://media2.giphy.com/media/v1.Y2lk/AbC123xyz/200w.webp":  "AbC123xyz.gif",
+		"https://i.giphy.com/AbC123xyz.gif":                           "AbC123xyz.gif",
+		"https://media.giphy.com/media/AbC123xyz/giphy-downsized.gif": "AbC123xyz.gif",
+	}
+	for link, filename := range tests {
+		files, err := getGiphyFiles(link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Link != "https://media.giphy.com/media/AbC123xyz/giphy.gif" || files[0].Filename != filename {
+			t.Errorf("%s = %+v", link, files)
+		}
+	}
+}
+
+func TestGiphyMediaExists(t *testing.T) {
+	config = defaultConfiguration()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("checked with %s", r.Method)
+		}
+		if r.URL.Path != "/giphy-hd.mp4" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if !giphyMediaExists(context.Background(), server.URL+"/giphy-hd.mp4") {
+		t.Error("existing rendition not found")
+	}
+	if giphyMediaExists(context.Background(), server.URL+"/missing.mp4") {
+		t.Error("missing rendition found")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Mastodon and compatible servers (Pleroma, Akkoma...) through /api/v1/statuses/:id.
+// Any host can be an instance, so this is a fallback and anything not answering like one is skipped.
+
+var (
+	regexUrlMastodonStatus = regexp.MustCompile(`^https?:\/\/([^\/]+)\/(@[A-Za-z0-9_.-]+(@[^\/]+)?|users\/[A-Za-z0-9_.-]+\/statuses)\/([0-9]+)\/?(\?.*)?$`)
+	// Pleroma & Akkoma, their IDs aren't numeric
+	regexUrlPleromaNotice = regexp.MustCompile(`^https?:\/\/([^\/]+)\/(notice)\/([0-9A-Za-z]+)\/?(\?.*)?$`)
+)
+
+var errNotMastodon = errors.New("not a mastodon status")
+
+func init() {
+	registerFallbackExtractor(&siteExtractor{
+		name: "mastodon",
+		routes: []extractorRoute{
+			{regexUrlMastodonStatus, getMastodonStatusFiles},
+			{regexUrlPleromaNotice, getMastodonStatusFiles},
+		},
+		ignoreError: func(err error) bool {
+			return errors.Is(err, errNotMastodon)
+		},
+	})
+}
+
+type mastodonStatus struct {
+	ID      string `json:"id"`
+	Account struct {
+		Acct string `json:"acct"`
+	} `json:"account"`
+	MediaAttachments []struct {
+		Type      string `json:"type"` // image, gifv, video, audio
+		URL       string `json:"url"`
+		RemoteURL string `json:"remote_url"`
+	} `json:"media_attachments"`
+	Reblog *mastodonStatus `json:"reblog"`
+}
+
+func getMastodonStatusFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Host
+	statusID := path.Base(strings.TrimSuffix(parsed.Path, "/"))
+
+	request, err := http.NewRequestWithContext(requestContext(m), "GET", "https://"+host+"/api/v1/statuses/"+url.PathEscape(statusID), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := doRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 || !strings.Contains(response.Header.Get("Content-Type"), "json") {
+		return nil, errNotMastodon
+	}
+	status := new(mastodonStatus)
+	if err := json.NewDecoder(response.Body).Decode(status); err != nil || status.ID == "" {
+		return nil, errNotMastodon
+	}
+	if status.Reblog != nil {
+		status = status.Reblog
+	}
+
+	prefix := fmt.Sprintf("Mastodon-%s_%s", status.Account.Acct, status.ID)
+	var media []*fileItem
+	for _, attachment := range status.MediaAttachments {
+		// The instance's copy is usually the original, unless it hasn't fetched a remote post's media yet
+		mediaURL := attachment.URL
+		if mediaURL == "" {
+			mediaURL = attachment.RemoteURL
+		}
+		if mediaURL != "" && attachment.Type != "unknown" {
+			media = append(media, &fileItem{Link: mediaURL, Filename: prefix + " " + filenameFromURL(mediaURL)})
+		}
+	}
+
+	return expandPostMedia(media, map[string]string{
+		"mastodonAuthor": status.Account.Acct,
+		"mastodonPostID": status.ID,
+	}, m), nil
+}
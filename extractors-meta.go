@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Last resort for pages on allowlisted domains, picks the media out of the Open Graph & Twitter card
+// tags or JSON-LD that most sites include for link previews.
+
+const metaPageTimeout = 30 * time.Second
+
+type metaExtractor struct{}
+
+func init() {
+	registerFallbackExtractor(&metaExtractor{})
+}
+
+type metaCandidate struct {
+	URL    string
+	Video  bool
+	Type   string // og:video:type and such, when the page says
+	Width  int
+	Height int
+}
+
+func (extractor *metaExtractor) Name() string {
+	return "meta"
+}
+
+func (extractor *metaExtractor) Match(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return hostInDomains(parsed.Hostname(), config.MetaExtractorDomains)
+}
+
+func (extractor *metaExtractor) NeedsAuth() bool {
+	return false
+}
+
+func (extractor *metaExtractor) Extract(link string, m *discordgo.Message) ([]*fileItem, error) {
+	candidates, err := getPageMetaCandidates(requestContext(m), link)
+	if err != nil {
+		return nil, err
+	}
+
+	// Videos over images (the image is usually just the thumbnail), then the largest
+	var best *metaCandidate
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.URL == link {
+			continue
+		}
+		if best == nil || (candidate.Video && !best.Video) ||
+			(candidate.Video == best.Video && candidate.Width*candidate.Height > best.Width*best.Height) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return []*fileItem{{Link: best.URL}}, nil
+}
+
+// getPageMetaCandidates fetches a page and lists the media in its preview tags & JSON-LD, URLs made
+// absolute. Nothing when the link turns out to be media itself, which is only fetched when its
+// extension doesn't already say so.
+func getPageMetaCandidates(ctx context.Context, link string) ([]metaCandidate, error) {
+	if linkMediaKind(link) != "" {
+		return nil, nil
+	}
+	request, err := http.NewRequestWithContext(withRequestTimeout(ctx, metaPageTimeout), "GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	response, err := doRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return nil, fmt.Errorf("page returned %d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+		return nil, nil // media already, nothing to dig out
+	}
+	doc, err := goquery.NewDocumentFromReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := getMetaTagCandidates(doc)
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var data interface{}
+		if json.Unmarshal([]byte(s.Text()), &data) == nil {
+			candidates = append(candidates, getJSONLDCandidates(data, "")...)
+		}
+	})
+
+	var resolvedCandidates []metaCandidate
+	for _, candidate := range candidates {
+		resolved, err := response.Request.URL.Parse(strings.TrimSpace(candidate.URL))
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		candidate.URL = resolved.String()
+		resolvedCandidates = append(resolvedCandidates, candidate)
+	}
+	return resolvedCandidates, nil
+}
+
+// linkMediaKind goes by the extension alone, "image", "video", "audio" or nothing.
+func linkMediaKind(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	extension := strings.ToLower(path.Ext(parsed.Path))
+	if extension == "" {
+		return ""
+	}
+	if kind := fileTypeFromExtension(extension); kind != "" {
+		return kind
+	}
+	mediaType := mime.TypeByExtension(extension)
+	if index := strings.Index(mediaType, "/"); index != -1 {
+		switch kind := mediaType[:index]; kind {
+		case "image", "video", "audio":
+			return kind
+		}
+	}
+	return ""
+}
+
+// Open Graph structured properties (og:image:width...) describe the og:image before them.
+func getMetaTagCandidates(doc *goquery.Document) []metaCandidate {
+	var candidates []metaCandidate
+	current := -1
+	doc.Find("meta").Each(func(_ int, s *goquery.Selection) {
+		property := strings.ToLower(s.AttrOr("property", s.AttrOr("name", "")))
+		content := s.AttrOr("content", "")
+		if content == "" {
+			return
+		}
+		switch property {
+		case "og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src":
+			if property == "og:image:secure_url" && current >= 0 && !candidates[current].Video {
+				candidates[current].URL = content
+				return
+			}
+			candidates = append(candidates, metaCandidate{URL: content})
+			current = len(candidates) - 1
+		case "og:video", "og:video:url", "og:video:secure_url", "twitter:player:stream":
+			if property == "og:video:secure_url" && current >= 0 && candidates[current].Video {
+				candidates[current].URL = content
+				return
+			}
+			candidates = append(candidates, metaCandidate{URL: content, Video: true})
+			current = len(candidates) - 1
+		case "og:video:type", "twitter:player:stream:content_type":
+			if current >= 0 && candidates[current].Video {
+				candidates[current].Type = content
+			}
+		case "og:image:width", "og:video:width", "twitter:image:width", "twitter:player:width":
+			if current >= 0 {
+				candidates[current].Width, _ = strconv.Atoi(content)
+			}
+		case "og:image:height", "og:video:height", "twitter:image:height", "twitter:player:height":
+			if current >= 0 {
+				candidates[current].Height, _ = strconv.Atoi(content)
+			}
+		}
+	})
+	doc.Find(`link[rel="image_src"]`).Each(func(_ int, s *goquery.Selection) {
+		if href := s.AttrOr("href", ""); href != "" {
+			candidates = append(candidates, metaCandidate{URL: href})
+		}
+	})
+
+	// Video tags often point at a player, a page of its own, only keep the ones that are files
+	var media []metaCandidate
+	for _, candidate := range candidates {
+		if candidate.Video && !strings.HasPrefix(strings.ToLower(candidate.Type), "video/") &&
+			linkMediaKind(candidate.URL) != "video" {
+			continue
+		}
+		media = append(media, candidate)
+	}
+	return media
+}
+
+// Walks JSON-LD for ImageObject/VideoObject entries and image properties, kind being
+// "image" or "video" when the value sits under a property saying so.
+func getJSONLDCandidates(data interface{}, kind string) []metaCandidate {
+	var candidates []metaCandidate
+	switch value := data.(type) {
+	case string:
+		if kind != "" {
+			candidates = append(candidates, metaCandidate{URL: value, Video: kind == "video"})
+		}
+	case []interface{}:
+		for _, item := range value {
+			candidates = append(candidates, getJSONLDCandidates(item, kind)...)
+		}
+	case map[string]interface{}:
+		objectType, _ := value["@type"].(string)
+		switch objectType {
+		case "ImageObject":
+			kind = "image"
+		case "VideoObject":
+			kind = "video"
+		}
+		if kind != "" {
+			for _, key := range []string{"contentUrl", "url"} {
+				if link, ok := value[key].(string); ok && link != "" {
+					candidates = append(candidates, metaCandidate{
+						URL:    link,
+						Video:  kind == "video",
+						Width:  jsonLDNumber(value["width"]),
+						Height: jsonLDNumber(value["height"]),
+					})
+					break
+				}
+			}
+		}
+		for key, child := range value {
+			switch key {
+			case "image", "thumbnail", "thumbnailUrl":
+				if kind != "video" { // a video's thumbnail isn't what was linked
+					candidates = append(candidates, getJSONLDCandidates(child, "image")...)
+				}
+			case "video":
+				candidates = append(candidates, getJSONLDCandidates(child, "video")...)
+			case "@graph", "mainEntity", "associatedMedia":
+				candidates = append(candidates, getJSONLDCandidates(child, "")...)
+			}
+		}
+	}
+	return candidates
+}
+
+// Sizes show up as numbers, strings like "1200" or "1200 px", or QuantitativeValue objects.
+func jsonLDNumber(value interface{}) int {
+	switch number := value.(type) {
+	case float64:
+		return int(number)
+	case string:
+		parsed, _ := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(number), "px")))
+		return parsed
+	case map[string]interface{}:
+		return jsonLDNumber(number["value"])
+	}
+	return 0
+}
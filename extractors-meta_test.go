@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestLinkMediaKind(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/photo.JPG":       "image",
+		"https://example.com/clip.mp4?x=1":    "video",
+		"https://example.com/clip.webm":       "video",
+		"https://example.com/song.mp3":        "audio",
+		"https://example.com/post/123":        "",
+		"https://example.com/page.html":       "",
+		"https://example.com/photo.jpg/page":  "",
+		"https://example.com/?file=photo.jpg": "",
+	}
+	for link, want := range tests {
+		if got := linkMediaKind(link); got != want {
+			t.Errorf("linkMediaKind(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func TestMetaTagCandidatesSkipsPlayers(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+<meta property="og:image" content="/thumb.jpg">
+<meta property="og:video" content="https://example.com/embed/1">
+<meta property="og:video:type" content="text/html">
+<meta property="og:video" content="https://example.com/stream/1">
+<meta property="og:video:type" content="video/mp4">
+<meta property="og:video:width" content="1280">
+<meta name="twitter:player:stream" content="https://example.com/player/2">
+<meta name="twitter:player:stream" content="https://example.com/files/2.webm">
+</head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []string
+	for _, candidate := range getMetaTagCandidates(doc) {
+		links = append(links, candidate.URL)
+	}
+	want := "/thumb.jpg https://example.com/stream/1 https://example.com/files/2.webm"
+	if got := strings.Join(links, " "); got != want {
+		t.Errorf("candidates = %s, want %s", got, want)
+	}
+}
+
+// Media links aren't fetched at all, the extension is enough to tell.
+func TestPageMetaCandidatesSkipsMediaLinks(t *testing.T) {
+	config = defaultConfiguration()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<meta property="og:image" content="/full.png">`))
+	}))
+	defer server.Close()
+
+	if candidates, err := getPageMetaCandidates(context.Background(), server.URL+"/video.mp4"); err != nil || candidates != nil || requests != 0 {
+		t.Errorf("media link: %v, %v, %d requests", candidates, err, requests)
+	}
+	candidates, err := getPageMetaCandidates(context.Background(), server.URL+"/post/1")
+	if err != nil || len(candidates) != 1 || candidates[0].URL != server.URL+"/full.png" {
+		t.Errorf("page: %+v, %v", candidates, err)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fatih/color"
+)
+
+// Reddit posts through the public JSON listing: link posts, galleries & Reddit hosted video,
+// following crossposts back to the original post. Hosted video keeps its audio in a separate
+// DASH stream, merging the two needs ffmpeg, otherwise the video is saved silent.
+
+var (
+	regexUrlRedditPost    = regexp.MustCompile(`^https?:\/\/((www|old|new|np|m)\.)?reddit\.com\/((r|u|user)\/[0-9a-zA-Z_-]+\/)?comments\/([0-9a-zA-Z]+)`)
+	regexUrlRedditGallery = regexp.MustCompile(`^https?:\/\/((www|old|new)\.)?reddit\.com\/gallery\/([0-9a-zA-Z]+)`)
+	regexUrlRedditShort   = regexp.MustCompile(`^https?:\/\/(www\.)?redd\.it\/([0-9a-zA-Z]+)\/?(\?.*)?$`)
+	// These only redirect to the post, file links on v.redd.it (DASH_720.mp4...) are left alone
+	regexUrlRedditShare = regexp.MustCompile(`^https?:\/\/((www|old|new|m)\.)?reddit\.com\/(r|u|user)\/[0-9a-zA-Z_-]+\/s\/[0-9a-zA-Z]+`)
+	regexUrlRedditVideo = regexp.MustCompile(`^https?:\/\/v\.redd\.it\/([0-9a-zA-Z]+)\/?(\?.*)?$`)
+)
+
+const redditMergeTimeout = 10 * time.Minute
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "reddit",
+		routes: []extractorRoute{
+			{regexUrlRedditPost, getRedditPostFiles},
+			{regexUrlRedditGallery, getRedditPostFiles},
+			{regexUrlRedditShort, getRedditPostFiles},
+			{regexUrlRedditShare, getRedditPostFiles},
+			{regexUrlRedditVideo, getRedditPostFiles},
+		},
+	})
+}
+
+type redditListing []struct {
+	Data struct {
+		Children []struct {
+			Kind string     `json:"kind"`
+			Data redditPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+type redditPost struct {
+	ID          string `json:"id"`
+	Subreddit   string `json:"subreddit"`
+	URL         string `json:"url_overridden_by_dest"`
+	IsSelf      bool   `json:"is_self"`
+	GalleryData *struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+	MediaMetadata map[string]struct {
+		Status string `json:"status"`
+		E      string `json:"e"` // Image or AnimatedImage
+		M      string `json:"m"` // mime type
+		S      struct {
+			GIF string `json:"gif"`
+			MP4 string `json:"mp4"`
+		} `json:"s"`
+	} `json:"media_metadata"`
+	Media               *redditMedia `json:"media"`
+	SecureMedia         *redditMedia `json:"secure_media"`
+	CrosspostParentList []redditPost `json:"crosspost_parent_list"`
+}
+
+type redditMedia struct {
+	RedditVideo *struct {
+		FallbackURL string `json:"fallback_url"`
+		DashURL     string `json:"dash_url"`
+		HasAudio    bool   `json:"has_audio"`
+		IsGIF       bool   `json:"is_gif"`
+	} `json:"reddit_video"`
+}
+
+func getRedditPostID(ctx context.Context, link string) (string, error) {
+	if matches := regexUrlRedditPost.FindStringSubmatch(link); matches != nil {
+		return matches[5], nil
+	}
+	if matches := regexUrlRedditGallery.FindStringSubmatch(link); matches != nil {
+		return matches[3], nil
+	}
+	if matches := regexUrlRedditShort.FindStringSubmatch(link); matches != nil {
+		return matches[2], nil
+	}
+
+	// Share links & v.redd.it only tell us the post by redirecting to it
+	request, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := doRequest(request)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	if matches := regexUrlRedditPost.FindStringSubmatch(response.Request.URL.String()); matches != nil {
+		return matches[5], nil
+	}
+	return "", fmt.Errorf("%s didn't redirect to a post", link)
+}
+
+func getRedditPostFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	ctx := requestContext(m)
+	postID, err := getRedditPostID(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	// raw_json keeps the API from HTML escaping every URL
+	listing := new(redditListing)
+	headers := map[string]string{"Accept-Encoding": "identity"}
+	if err := getJSONwithHeaders(ctx, "https://www.reddit.com/comments/"+postID+".json?raw_json=1", listing, headers); err != nil {
+		return nil, fmt.Errorf("failed to parse json from reddit post:\t%s", err)
+	}
+	if len(*listing) == 0 || len((*listing)[0].Data.Children) == 0 {
+		return nil, errors.New("reddit post not found")
+	}
+	post := (*listing)[0].Data.Children[0].Data
+	// Crossposts are empty shells, the media (and the keys) belong to the original
+	if len(post.CrosspostParentList) > 0 {
+		post = post.CrosspostParentList[0]
+	}
+
+	prefix := fmt.Sprintf("Reddit-%s_%s", post.Subreddit, post.ID)
+	var items []*fileItem
+	switch {
+	case post.GalleryData != nil:
+		for i, entry := range post.GalleryData.Items {
+			media, ok := post.MediaMetadata[entry.MediaID]
+			if !ok || media.Status != "valid" {
+				continue
+			}
+			mediaURL := ""
+			if media.E == "AnimatedImage" {
+				mediaURL = media.S.MP4
+				if mediaURL == "" {
+					mediaURL = media.S.GIF
+				}
+			} else if extension := strings.TrimPrefix(media.M, "image/"); extension != "" {
+				mediaURL = "https://i.redd.it/" + entry.MediaID + "." + extension
+			}
+			if mediaURL != "" {
+				items = append(items, &fileItem{
+					Link:     mediaURL,
+					Filename: fmt.Sprintf("%s %02d %s", prefix, i+1, filenameFromURL(mediaURL)),
+				})
+			}
+		}
+
+	case post.SecureMedia != nil && post.SecureMedia.RedditVideo != nil:
+		items, err = getRedditVideo(post, post.SecureMedia, link, m)
+	case post.Media != nil && post.Media.RedditVideo != nil:
+		items, err = getRedditVideo(post, post.Media, link, m)
+
+	case post.URL != "" && !post.IsSelf:
+		// Anything linked elsewhere goes through the other extractors, but not back through this one
+		if isRedditPostLink(post.URL) {
+			return nil, nil
+		}
+		for _, item := range getParsedLinks(post.URL, m) {
+			if item.Filename == "" {
+				item.Filename = prefix + " " + filenameFromURL(item.Link)
+			}
+			items = append(items, item)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.DataKeys == nil {
+			item.DataKeys = make(map[string]string)
+		}
+		item.DataKeys["redditSubreddit"] = post.Subreddit
+		item.DataKeys["redditPostID"] = post.ID
+	}
+	return items, nil
+}
+
+func isRedditPostLink(link string) bool {
+	return regexUrlRedditPost.MatchString(link) || regexUrlRedditGallery.MatchString(link) ||
+		regexUrlRedditShort.MatchString(link) || regexUrlRedditShare.MatchString(link) ||
+		regexUrlRedditVideo.MatchString(link)
+}
+
+//#region Hosted Video
+
+func getRedditVideo(post redditPost, media *redditMedia, link string, m *discordgo.Message) ([]*fileItem, error) {
+	video := media.RedditVideo
+	videoURL := video.FallbackURL
+	if index := strings.Index(videoURL, "?"); index != -1 {
+		videoURL = videoURL[:index]
+	}
+	if videoURL == "" {
+		return nil, errors.New("reddit video has no fallback url")
+	}
+	filename := fmt.Sprintf("Reddit-%s_%s.mp4", post.Subreddit, post.ID)
+	silent := []*fileItem{{Link: videoURL, Filename: filename}}
+	if !video.HasAudio || video.IsGIF {
+		return silent, nil
+	}
+
+	ffmpeg := getFFmpegPath()
+	if ffmpeg == "" {
+		log.Println(lg("Download", "Reddit", color.YellowString,
+			"ffmpeg not found, saving reddit video %s without audio", post.ID))
+		return silent, nil
+	}
+	// Merging means downloading both first, left to the worker
+	return []*fileItem{{Link: link, Filename: filename, Resolve: func() ([]*fileItem, error) {
+		return mergeRedditVideo(post, videoURL, video.DashURL, filename, link, m, silent)
+	}}}, nil
+}
+
+func mergeRedditVideo(post redditPost, videoURL string, dashURL string, filename string, link string,
+	m *discordgo.Message, silent []*fileItem) ([]*fileItem, error) {
+	ffmpeg := getFFmpegPath()
+	ctx, cancel := context.WithTimeout(requestContext(m), redditMergeTimeout)
+	defer cancel()
+	dir, err := os.MkdirTemp("", "ddg-reddit-")
+	if err != nil {
+		return nil, err
+	}
+	videoPath := filepath.Join(dir, "video.mp4")
+	if err := downloadToFile(ctx, videoURL, videoPath); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	audioPath := filepath.Join(dir, "audio.mp4")
+	audioFound := false
+	for _, audioURL := range getRedditAudioURLs(ctx, videoURL, dashURL) {
+		if downloadToFile(ctx, audioURL, audioPath) == nil {
+			audioFound = true
+			break
+		}
+	}
+	if !audioFound {
+		os.RemoveAll(dir)
+		return silent, nil
+	}
+
+	outputPath := filepath.Join(dir, filename)
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error",
+		"-i", videoPath, "-i", audioPath, "-map", "0:v", "-map", "1:a", "-c", "copy", outputPath)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		log.Println(lg("Download", "Reddit", color.YellowString,
+			"Failed to merge audio into reddit video %s, saving it without -- %s %s", post.ID, err, strings.TrimSpace(stderr.String())))
+		return silent, nil
+	}
+	os.Remove(videoPath)
+	os.Remove(audioPath)
+	return []*fileItem{{Link: stageFile(outputPath, link), Filename: filename}}, nil
+}
+
+type redditDashManifest struct {
+	Periods []struct {
+		AdaptationSets []struct {
+			ContentType     string `xml:"contentType,attr"`
+			MimeType        string `xml:"mimeType,attr"`
+			Representations []struct {
+				Bandwidth int    `xml:"bandwidth,attr"`
+				MimeType  string `xml:"mimeType,attr"`
+				BaseURL   string `xml:"BaseURL"`
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+// The best audio stream listed in the manifest, then the names Reddit has used over the years.
+func getRedditAudioURLs(ctx context.Context, videoURL string, dashURL string) []string {
+	base := videoURL[:strings.LastIndex(videoURL, "/")+1]
+	var urls []string
+	if dashURL != "" {
+		if request, err := http.NewRequestWithContext(ctx, "GET", dashURL, nil); err == nil {
+			if response, err := doRequest(request); err == nil {
+				var manifest redditDashManifest
+				if response.StatusCode < 400 && xml.NewDecoder(response.Body).Decode(&manifest) == nil {
+					best, bestBandwidth := "", -1
+					for _, period := range manifest.Periods {
+						for _, set := range period.AdaptationSets {
+							for _, representation := range set.Representations {
+								isAudio := set.ContentType == "audio" || strings.HasPrefix(set.MimeType, "audio/") ||
+									strings.HasPrefix(representation.MimeType, "audio/")
+								if isAudio && representation.BaseURL != "" && representation.Bandwidth > bestBandwidth {
+									best, bestBandwidth = strings.TrimSpace(representation.BaseURL), representation.Bandwidth
+								}
+							}
+						}
+					}
+					if best != "" {
+						urls = append(urls, base+best)
+					}
+				}
+				response.Body.Close()
+			}
+		}
+	}
+	return append(urls, base+"DASH_AUDIO_128.mp4", base+"DASH_audio.mp4", base+"audio")
+}
+
+func downloadToFile(ctx context.Context, link string, path string) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return err
+	}
+	response, err := doRequest(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %d %s", link, response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, response.Body); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Settings first, then whatever's on PATH.
+func getFFmpegPath() string {
+	if config.FFmpegPath != "" {
+		return config.FFmpegPath
+	}
+	if path, err := exec.LookPath("ffmpeg"); err == nil {
+		return path
+	}
+	return ""
+}
+
+//#endregion
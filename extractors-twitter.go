@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Tweets become a twitterPost whether they came from the logged-in scraper (parse.go) or the public
+// APIs here, so quoted tweets & threads are followed the same way. Without a login statuses go through
+// the syndication API behind embedded tweets, then the FxTwitter API. That's a fallback extractor,
+// so it also picks up statuses when the scraper fails.
+
+const (
+	twitterSyndicationAPI = "https://cdn.syndication.twimg.com/tweet-result"
+	twitterFxAPI          = "https://api.fxtwitter.com/status/"
+
+	twitterThreadMaxTweets = 25
+	twitterTextMaxLength   = 100
+)
+
+// Media links are left at the end of the text
+var regexTwitterShortLink = regexp.MustCompile(`https?:\/\/t\.co\/[A-Za-z0-9]+`)
+
+func init() {
+	registerFallbackExtractor(&siteExtractor{
+		name:   "twitter-syndication",
+		routes: []extractorRoute{{regexUrlTwitterStatus, getTwitterSyndicationFiles}},
+		ignoreError: func(err error) bool {
+			return strings.Contains(err.Error(), "unavailable")
+		},
+	})
+}
+
+//#region Posts
+
+type twitterPost struct {
+	ID     string
+	Author string
+	Text   string
+	Time   time.Time
+	Media  []string
+	// Filled in when the API gave them along with the tweet, otherwise looked up by ID
+	Quoted    *twitterPost
+	QuotedID  string
+	ReplyTo   *twitterPost
+	ReplyToID string
+}
+
+func getTwitterStatusID(link string) (string, error) {
+	link = strings.Split(strings.Split(link, "/photo/")[0], "/video/")[0]
+	matches := regexUrlTwitterStatus.FindStringSubmatch(link)
+	if matches == nil {
+		return "", errors.New("unable to parse Twitter URL")
+	}
+	if _, err := strconv.ParseInt(matches[4], 10, 64); err != nil {
+		return "", err
+	}
+	return matches[4], nil
+}
+
+// twitterThreadFiles gathers the media of a tweet, and depending on settings the earlier tweets of
+// its thread by the same author & the tweet it quotes, each file keeping the keys of its own tweet.
+func twitterThreadFiles(tweet *twitterPost, getPost func(tweetID string) (*twitterPost, error), m *discordgo.Message) []*fileItem {
+	posts := []*twitterPost{tweet}
+	if config.TwitterThreads {
+		for parent := tweet; len(posts) <= twitterThreadMaxTweets && parent.ReplyToID != ""; {
+			next := parent.ReplyTo
+			if next == nil || next.ID != parent.ReplyToID {
+				var err error
+				if next, err = getPost(parent.ReplyToID); err != nil {
+					break // deleted or protected, the thread ends there as far as we can see
+				}
+			}
+			if !strings.EqualFold(next.Author, tweet.Author) {
+				break
+			}
+			posts = append([]*twitterPost{next}, posts...)
+			parent = next
+		}
+	}
+	if config.TwitterQuotedTweets && tweet.QuotedID != "" {
+		quoted := tweet.Quoted
+		if quoted == nil {
+			quoted, _ = getPost(tweet.QuotedID)
+		}
+		if quoted != nil {
+			posts = append(posts, quoted)
+		}
+	}
+
+	var items []*fileItem
+	for _, post := range posts {
+		var media []*fileItem
+		for _, link := range post.Media {
+			media = append(media, &fileItem{
+				Link:     link,
+				Filename: filenameFromURL(strings.TrimSuffix(link, ":orig")),
+				PostTime: post.Time,
+			})
+		}
+		items = append(items, expandPostMedia(media, post.keys(), m)...)
+	}
+	return items
+}
+
+func (post *twitterPost) keys() map[string]string {
+	text := strings.Join(strings.Fields(regexTwitterShortLink.ReplaceAllString(post.Text, "")), " ")
+	if runes := []rune(text); len(runes) > twitterTextMaxLength {
+		text = strings.TrimSpace(string(runes[:twitterTextMaxLength]))
+	}
+	date := ""
+	if !post.Time.IsZero() {
+		date = post.Time.Format(config.FilenameDateFormat)
+	}
+	return map[string]string{
+		"tweetAuthor": post.Author,
+		"tweetID":     post.ID,
+		"tweetText":   text,
+		"tweetDate":   date,
+	}
+}
+
+//#endregion
+
+//#region Public APIs
+
+func getTwitterSyndicationFiles(link string, m *discordgo.Message) ([]*fileItem, error) {
+	tweetID, err := getTwitterStatusID(link)
+	if err != nil {
+		return nil, nil
+	}
+	ctx := requestContext(m)
+	getPost := func(tweetID string) (*twitterPost, error) {
+		return getTwitterPublicPost(ctx, tweetID)
+	}
+	tweet, err := getPost(tweetID)
+	if err != nil {
+		return nil, err
+	}
+	return twitterThreadFiles(tweet, getPost, m), nil
+}
+
+func getTwitterPublicPost(ctx context.Context, tweetID string) (*twitterPost, error) {
+	post, err := getTwitterSyndicationPost(ctx, tweetID)
+	if err != nil {
+		var fxErr error
+		if post, fxErr = getTwitterFxPost(ctx, tweetID); fxErr != nil {
+			return nil, fmt.Errorf("%s, fxtwitter: %s", err, fxErr)
+		}
+	}
+	return post, nil
+}
+
+type twitterSyndicationTweet struct {
+	Typename string `json:"__typename"`
+	IDStr    string `json:"id_str"`
+	Text     string `json:"text"`
+	Created  string `json:"created_at"`
+	User     struct {
+		ScreenName string `json:"screen_name"`
+	} `json:"user"`
+	MediaDetails []struct {
+		Type          string `json:"type"` // photo, video or animated_gif
+		MediaURLHTTPS string `json:"media_url_https"`
+		VideoInfo     struct {
+			Variants []twitterVideoVariant `json:"variants"`
+		} `json:"video_info"`
+	} `json:"mediaDetails"`
+	QuotedTweet     *twitterSyndicationTweet `json:"quoted_tweet"`
+	Parent          *twitterSyndicationTweet `json:"parent"`
+	InReplyToStatus string                   `json:"in_reply_to_status_id_str"`
+}
+
+func (tweet *twitterSyndicationTweet) post() *twitterPost {
+	post := &twitterPost{
+		ID:        tweet.IDStr,
+		Author:    tweet.User.ScreenName,
+		Text:      tweet.Text,
+		ReplyToID: tweet.InReplyToStatus,
+	}
+	post.Time, _ = time.Parse(time.RFC3339, tweet.Created)
+	for _, media := range tweet.MediaDetails {
+		switch media.Type {
+		case "photo":
+			post.Media = append(post.Media, twitterOriginalImage(media.MediaURLHTTPS))
+		case "video", "animated_gif":
+			if best := bestTwitterVariant(media.VideoInfo.Variants); best != "" {
+				post.Media = append(post.Media, best)
+			}
+		}
+	}
+	if tweet.QuotedTweet != nil && tweet.QuotedTweet.IDStr != "" {
+		post.Quoted = tweet.QuotedTweet.post()
+		post.QuotedID = post.Quoted.ID
+	}
+	if tweet.Parent != nil && tweet.Parent.IDStr != "" {
+		post.ReplyTo = tweet.Parent.post()
+	}
+	return post
+}
+
+type twitterVideoVariant struct {
+	Bitrate     int    `json:"bitrate"`
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
+}
+
+func getTwitterSyndicationPost(ctx context.Context, tweetID string) (*twitterPost, error) {
+	id, err := strconv.ParseInt(tweetID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	tweet := new(twitterSyndicationTweet)
+	apiURL := twitterSyndicationAPI + "?lang=en&id=" + tweetID + "&token=" + twitterSyndicationToken(id)
+	if err := getJSON(ctx, apiURL, tweet); err != nil {
+		return nil, fmt.Errorf("failed to parse json from syndication: %s", err)
+	}
+	if tweet.Typename == "TweetTombstone" || tweet.IDStr == "" {
+		return nil, errors.New("tweet unavailable")
+	}
+	return tweet.post(), nil
+}
+
+type twitterFxTweet struct {
+	ID               string `json:"id"`
+	Text             string `json:"text"`
+	CreatedTimestamp int64  `json:"created_timestamp"`
+	Author           struct {
+		ScreenName string `json:"screen_name"`
+	} `json:"author"`
+	Media struct {
+		Photos []struct {
+			URL string `json:"url"`
+		} `json:"photos"`
+		Videos []struct {
+			URL      string                `json:"url"`
+			Variants []twitterVideoVariant `json:"variants"`
+		} `json:"videos"`
+	} `json:"media"`
+	Quote            *twitterFxTweet `json:"quote"`
+	ReplyingToStatus string          `json:"replying_to_status"`
+}
+
+func (tweet *twitterFxTweet) post() *twitterPost {
+	post := &twitterPost{
+		ID:        tweet.ID,
+		Author:    tweet.Author.ScreenName,
+		Text:      tweet.Text,
+		ReplyToID: tweet.ReplyingToStatus,
+	}
+	if tweet.CreatedTimestamp > 0 {
+		post.Time = time.Unix(tweet.CreatedTimestamp, 0)
+	}
+	for _, photo := range tweet.Media.Photos {
+		post.Media = append(post.Media, twitterOriginalImage(photo.URL))
+	}
+	for _, video := range tweet.Media.Videos {
+		if best := bestTwitterVariant(video.Variants); best != "" {
+			post.Media = append(post.Media, best)
+		} else if video.URL != "" {
+			post.Media = append(post.Media, video.URL)
+		}
+	}
+	if tweet.Quote != nil && tweet.Quote.ID != "" {
+		post.Quoted = tweet.Quote.post()
+		post.QuotedID = post.Quoted.ID
+	}
+	return post
+}
+
+func getTwitterFxPost(ctx context.Context, tweetID string) (*twitterPost, error) {
+	var response struct {
+		Code  int            `json:"code"`
+		Tweet twitterFxTweet `json:"tweet"`
+	}
+	if err := getJSON(ctx, twitterFxAPI+tweetID, &response); err != nil {
+		return nil, err
+	}
+	if response.Code != 200 {
+		return nil, fmt.Errorf("tweet unavailable (%d)", response.Code)
+	}
+	return response.Tweet.post(), nil
+}
+
+// The MP4 with the highest bitrate, streaming playlists left out.
+func bestTwitterVariant(variants []twitterVideoVariant) string {
+	best, bestBitrate := "", -1
+	for _, variant := range variants {
+		if variant.ContentType == "video/mp4" && variant.Bitrate > bestBitrate {
+			best, bestBitrate = variant.URL, variant.Bitrate
+		}
+	}
+	return best
+}
+
+// Images are served resized unless asked for the original.
+func twitterOriginalImage(imageURL string) string {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || !strings.HasSuffix(parsed.Hostname(), "twimg.com") {
+		return imageURL
+	}
+	parsed.RawQuery = ""
+	return strings.TrimSuffix(parsed.String(), ":orig") + ":orig"
+}
+
+//#endregion
+
+//#region Syndication Token
+
+// The syndication API wants the token embedded tweets send, which is the ID worked into a float
+// and written out in base 36 by JavaScript: ((id / 1e15) * Math.PI).toString(36) without 0s & dots.
+func twitterSyndicationToken(id int64) string {
+	return strings.NewReplacer("0", "", ".", "").Replace(javascriptRadixString((float64(id)/1e15)*math.Pi, 36))
+}
+
+// Number.prototype.toString(radix) for positive numbers, digit for digit as V8 writes fractions.
+func javascriptRadixString(value float64, radix int) string {
+	const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	integer := math.Floor(value)
+	fraction := value - integer
+	// Only as many fraction digits as it takes to tell value from its neighbouring doubles
+	delta := math.Max(0.5*(math.Nextafter(value, math.Inf(1))-value), math.Nextafter(0, 1))
+
+	var fractionDigits []byte
+	if fraction >= delta {
+		for {
+			fraction *= float64(radix)
+			delta *= float64(radix)
+			digit := int(fraction)
+			fractionDigits = append(fractionDigits, digits[digit])
+			fraction -= float64(digit)
+			if fraction > 0.5 || (fraction == 0.5 && digit&1 == 1) {
+				if fraction+delta > 1 {
+					// Round up, carrying into the integer part when every digit rolls over
+					for {
+						last := len(fractionDigits) - 1
+						if last < 0 {
+							integer++
+							break
+						}
+						digit := strings.IndexByte(digits, fractionDigits[last])
+						fractionDigits = fractionDigits[:last]
+						if digit+1 < radix {
+							fractionDigits = append(fractionDigits, digits[digit+1])
+							break
+						}
+					}
+					break
+				}
+			}
+			if fraction < delta {
+				break
+			}
+		}
+	}
+
+	result := strconv.FormatInt(int64(integer), radix)
+	if len(fractionDigits) > 0 {
+		result += "." + string(fractionDigits)
+	}
+	return result
+}
+
+//#endregion
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// Expected values from Node, ((id / 1e15) * Math.PI).toString(36) with zeros & the point removed.
+func TestTwitterSyndicationToken(t *testing.T) {
+	tests := map[int64]string{
+		1:                   "bhi2ay3f28n",
+		20:                  "6dq1a2xwd93",
+		463440424141459456:  "14fxvks611f",
+		1234567890123456789: "2zqic77uqyk",
+		1580661436132757506: "3txslhgbjje",
+		1700000000000000000: "44cpgxmyurn",
+	}
+	for id, want := range tests {
+		if got := twitterSyndicationToken(id); got != want {
+			t.Errorf("twitterSyndicationToken(%d) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestJavascriptRadixString(t *testing.T) {
+	tests := []struct {
+		value float64
+		radix int
+		want  string
+	}{
+		{0.5, 36, "0.i"},
+		{255.75, 16, "ff.c"},
+		{255, 16, "ff"},
+		{0.1, 2, "0.0001100110011001100110011001100110011001100110011001101"},
+		{1e-9 * 3.141592653589793, 36, "0.000006u6s0hudm3l"},
+	}
+	for _, test := range tests {
+		if got := javascriptRadixString(test.value, test.radix); got != test.want {
+			t.Errorf("javascriptRadixString(%v, %d) = %q, want %q", test.value, test.radix, got, test.want)
+		}
+	}
+}
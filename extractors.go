@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fatih/color"
+)
+
+// Extractor turns a link to a post, album or page into the media links it holds.
+// Sites register themselves from the file they're implemented in, see parse.go.
+type Extractor interface {
+	// Name is used for settings & stats, lowercase without spaces.
+	Name() string
+	Match(link string) bool
+	// Extract returns the files to download, empty filenames are worked out when downloading.
+	Extract(link string, m *discordgo.Message) ([]*fileItem, error)
+	// NeedsAuth is true while the extractor is waiting on a login it can't work without.
+	NeedsAuth() bool
+}
+
+type extractorStats struct {
+	Matched       int
+	Succeeded     int
+	Failed        int
+	LastError     string
+	LastErrorTime time.Time
+}
+
+var (
+	extractors         []Extractor
+	fallbackExtractors []Extractor // tried after every other extractor, for broad patterns
+
+	extractorStatsMutex sync.Mutex
+	extractorStatsMap   = map[string]*extractorStats{}
+)
+
+func registerExtractor(extractor Extractor) {
+	extractors = append(extractors, extractor)
+}
+
+func registerFallbackExtractor(extractor Extractor) {
+	fallbackExtractors = append(fallbackExtractors, extractor)
+}
+
+// Site extractors, then external tools from settings, then fallbacks.
+func allExtractors() []Extractor {
+	external := getExternalExtractors()
+	all := make([]Extractor, 0, len(extractors)+len(external)+len(fallbackExtractors))
+	all = append(all, extractors...)
+	all = append(all, external...)
+	return append(all, fallbackExtractors...)
+}
+
+func extractorEnabled(name string) bool {
+	if setting, ok := config.Extractors[name]; ok && setting != nil && setting.Enabled != nil {
+		return *setting.Enabled
+	}
+	return true
+}
+
+// runExtractors returns the files from the first extractor that finds any.
+func runExtractors(link string, m *discordgo.Message) []*fileItem {
+	for _, extractor := range allExtractors() {
+		if !extractorEnabled(extractor.Name()) || extractor.NeedsAuth() || !extractor.Match(link) {
+			continue
+		}
+		items, err := extractor.Extract(link, m)
+		if err != nil {
+			err = errors.New(redactURLQueries(err.Error()))
+		}
+		recordExtractorResult(extractor.Name(), len(items), err)
+		if err != nil {
+			log.Println(lg("Download", "", color.RedString,
+				"%s extraction failed for %s -- %s", extractor.Name(), link, err))
+		} else if len(items) > 0 {
+			return items
+		}
+	}
+	return nil
+}
+
+// Queries can hold API keys (Gelbooru only takes them there) and request errors include the whole URL,
+// so they're cut from extractor errors before those are logged or kept for the stats posted to Discord.
+var regexURLQuery = regexp.MustCompile(`(https?:\/\/[^\s?#"']+)\?[^\s#"']*`)
+
+func redactURLQueries(text string) string {
+	return regexURLQuery.ReplaceAllString(text, "$1?[redacted]")
+}
+
+func recordExtractorResult(name string, found int, err error) {
+	extractorStatsMutex.Lock()
+	defer extractorStatsMutex.Unlock()
+	stats, exists := extractorStatsMap[name]
+	if !exists {
+		stats = &extractorStats{}
+		extractorStatsMap[name] = stats
+	}
+	stats.Matched++
+	if err != nil {
+		stats.Failed++
+		stats.LastError = err.Error()
+		stats.LastErrorTime = time.Now()
+	} else if found > 0 {
+		stats.Succeeded++
+	}
+}
+
+func formatExtractorStats() string {
+	extractorStatsMutex.Lock()
+	defer extractorStatsMutex.Unlock()
+	var lines []string
+	for _, extractor := range allExtractors() {
+		name := extractor.Name()
+		state := "enabled"
+		if !extractorEnabled(name) {
+			state = "disabled"
+		} else if extractor.NeedsAuth() {
+			state = "needs login"
+		}
+		line := fmt.Sprintf("• **%s** (%s)", name, state)
+		if stats, exists := extractorStatsMap[name]; exists {
+			line += fmt.Sprintf(" — %d matched, %d found media, %d failed",
+				stats.Matched, stats.Succeeded, stats.Failed)
+			if stats.LastError != "" {
+				line += fmt.Sprintf("\n  last error %s ago: `%s`",
+					timeSinceShort(stats.LastErrorTime), truncateString(stats.LastError, 150))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandPostMedia runs the media found in a post back through getParsedLinks like Twitter statuses do,
+// so they're pruned the same way, keeping the names given here and tagging each file with the post's keys.
+func expandPostMedia(media []*fileItem, keys map[string]string, m *discordgo.Message) []*fileItem {
+	var items []*fileItem
+	for _, found := range media {
+		for _, item := range getParsedLinks(found.Link, m) {
+			if item.Filename == "" {
+				item.Filename = found.Filename
+			}
+			if item.PostTime.IsZero() {
+				item.PostTime = found.PostTime
+			}
+			if item.DataKeys == nil {
+				item.DataKeys = make(map[string]string)
+			}
+			for key, value := range keys {
+				item.DataKeys[key] = value
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+//#region Site Extractors
+
+// A site with one or more URL patterns, each handled by its own function.
+type siteExtractor struct {
+	name      string
+	routes    []extractorRoute
+	needsAuth func() bool
+	// Errors not worth logging or counting, like posts that were deleted.
+	ignoreError func(err error) bool
+}
+
+type extractorRoute struct {
+	regex   *regexp.Regexp
+	extract func(link string, m *discordgo.Message) ([]*fileItem, error)
+}
+
+func (site *siteExtractor) Name() string {
+	return site.name
+}
+
+func (site *siteExtractor) Match(link string) bool {
+	for _, route := range site.routes {
+		if route.regex.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract tries each matching route in order until one comes up with links.
+func (site *siteExtractor) Extract(link string, m *discordgo.Message) ([]*fileItem, error) {
+	var lastErr error
+	for _, route := range site.routes {
+		if !route.regex.MatchString(link) {
+			continue
+		}
+		items, err := route.extract(link, m)
+		if err != nil {
+			if site.ignoreError == nil || !site.ignoreError(err) {
+				lastErr = err
+			}
+			continue
+		}
+		if len(items) > 0 {
+			return items, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func (site *siteExtractor) NeedsAuth() bool {
+	return site.needsAuth != nil && site.needsAuth()
+}
+
+// For helpers that only come up with links & filenames
+func withLinks(extract func(link string, m *discordgo.Message) (map[string]string, error)) func(string, *discordgo.Message) ([]*fileItem, error) {
+	return func(link string, m *discordgo.Message) ([]*fileItem, error) {
+		links, err := extract(link, m)
+		return fileItemsFromLinks(links), err
+	}
+}
+
+// Same, for helpers that don't need the message either
+func ignoreMessage(extract func(link string) (map[string]string, error)) func(string, *discordgo.Message) ([]*fileItem, error) {
+	return func(link string, _ *discordgo.Message) ([]*fileItem, error) {
+		links, err := extract(link)
+		return fileItemsFromLinks(links), err
+	}
+}
+
+//#endregion
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactURLQueries(t *testing.T) {
+	err := fmt.Errorf("failed to parse json from gelbooru.com post:\t%s", &url.Error{
+		Op:  "Get",
+		URL: "https://gelbooru.com/index.php?page=dapi&s=post&q=index&json=1&id=1&user_id=42&api_key=secret",
+		Err: errors.New("connection reset"),
+	})
+	redacted := redactURLQueries(err.Error())
+	if strings.Contains(redacted, "secret") || strings.Contains(redacted, "user_id") {
+		t.Errorf("credentials left in %q", redacted)
+	}
+	if !strings.Contains(redacted, "https://gelbooru.com/index.php?[redacted]") || !strings.HasSuffix(redacted, "connection reset") {
+		t.Errorf("redacted = %q", redacted)
+	}
+	if text := "no media found at https://example.com/post/1"; redactURLQueries(text) != text {
+		t.Errorf("changed %q", text)
+	}
+}
@@ -350,6 +350,7 @@ func handleMessage(m *discordgo.Message, c *discordgo.Channel, edited bool, hist
 
 		// Process Files
 		var downloadedItems []downloadedItem
+		var downloads []downloadRequestStruct
 		files := getLinksByMessage(m)
 		for _, file := range files {
 			if file.Link == "" {
@@ -358,24 +359,31 @@ func handleMessage(m *discordgo.Message, c *discordgo.Channel, edited bool, hist
 			if config.Debug && (!history || config.MessageOutputHistory) {
 				log.Println(lg("Debug", "Message", color.HiCyanString, "FOUND FILE: "+file.Link+fmt.Sprintf(" \t<%s>", m.ID)))
 			}
-			status, filesize := downloadRequestStruct{
+			downloads = append(downloads, downloadRequestStruct{
 				InputURL:     file.Link,
 				Filename:     file.Filename,
 				Path:         sourceConfig.Destination,
 				Message:      m,
 				Channel:      c,
 				FileTime:     file.Time,
+				PostTime:     file.PostTime,
 				HistoryCmd:   history,
 				EmojiCmd:     false,
-				StartTime:    time.Now(),
 				AttachmentID: file.AttachmentID,
-			}.handleDownload()
-			if status.Status == downloadSuccess {
-				domain, _ := getDomain(file.Link)
+				DataKeys:     file.DataKeys,
+
+				OriginMessageID: file.OriginMessageID,
+				OriginType:      file.OriginType,
+				Resolve:         file.Resolve,
+			})
+		}
+		for i, result := range queueDownloads(downloads) {
+			if result.Status.Status == downloadSuccess {
+				domain, _ := getDomain(downloads[i].InputURL)
 				downloadedItems = append(downloadedItems, downloadedItem{
-					URL:      file.Link,
+					URL:      downloads[i].InputURL,
 					Domain:   domain,
-					Filesize: filesize,
+					Filesize: result.Filesize,
 				})
 			}
 		}
@@ -106,7 +106,9 @@ func main() {
 	//#region <<< CRITICAL INIT >>>
 
 	loadConfig()
+	setupHTTPClient()
 	openDatabase()
+	sweepPartialDownloads()
 
 	//#endregion
 
@@ -123,6 +125,8 @@ func main() {
 
 	mainWg.Wait() // wait because credentials from config
 
+	startDownloadWorkers()
+
 	//#region <<< CONNECTIONS >>>
 
 	mainWg.Add(2)
@@ -133,17 +137,6 @@ func main() {
 
 	//#endregion
 
-	//#region Initialize Regex
-	mainWg.Add(1)
-	go func() {
-		if err = compileRegex(); err != nil {
-			log.Println(lg("Regex", "", color.HiRedString, "Error initializing:\t%s", err))
-			return
-		}
-		mainWg.Done()
-	}()
-	//#endregion
-
 	//#region [Loops] History Job Processing
 	go func() {
 		for {
@@ -380,6 +373,17 @@ func main() {
 	}()
 	//#endregion
 
+	//#region [Loop] Failed Download Sweeper
+	if config.FailedDownloadSweepRate > 0 {
+		go func() {
+			tickerSweep := time.NewTicker(time.Duration(config.FailedDownloadSweepRate) * time.Minute)
+			for range tickerSweep.C {
+				sweepDownloadFailures()
+			}
+		}()
+	}
+	//#endregion
+
 	//#region [Loop] Settings Watcher
 	if config.WatchSettings {
 		watcher, err := fsnotify.NewWatcher()
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,8 +24,7 @@ import (
 )
 
 const (
-	imgurClientID   = "08af502a9e70d65"
-	sneakyUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/65.0.3325.181 Safari/537.36"
+	imgurClientID = "08af502a9e70d65"
 )
 
 func botLoadAPIs() {
@@ -254,6 +255,25 @@ func botLoadAPIs() {
 
 //#region Twitter
 
+var (
+	regexUrlTwitter       = regexp.MustCompile(`^http(s?):\/\/pbs(-[0-9]+)?\.twimg\.com\/media\/[^\./]+\.(jpg|png)((\:[a-z]+)?)$`)
+	regexUrlTwitterStatus = regexp.MustCompile(`^http(s?):\/\/(www\.)?twitter\.com\/([A-Za-z0-9-_\.]+\/status\/|statuses\/|i\/web\/status\/)([0-9]+)$`)
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "twitter",
+		routes: []extractorRoute{
+			{regexUrlTwitter, ignoreMessage(getTwitterUrls)},
+			{regexUrlTwitterStatus, getTwitterStatusFiles},
+		},
+		needsAuth: func() bool { return !twitterConnected },
+		ignoreError: func(err error) bool {
+			return strings.Contains(err.Error(), "suspended") || strings.Contains(err.Error(), "No status found")
+		},
+	})
+}
+
 func getTwitterUrls(inputURL string) (map[string]string, error) {
 	parts := strings.Split(inputURL, ":")
 	if len(parts) < 2 {
@@ -262,58 +282,87 @@ func getTwitterUrls(inputURL string) (map[string]string, error) {
 	return map[string]string{"https:" + parts[1] + ":orig": filenameFromURL(parts[1])}, nil
 }
 
-func getTwitterStatusUrls(inputURL string, m *discordgo.Message) (map[string]string, error) {
-	if strings.Contains(inputURL, "/photo/") {
-		inputURL = inputURL[:strings.Index(inputURL, "/photo/")]
-	}
-	if strings.Contains(inputURL, "/video/") {
-		inputURL = inputURL[:strings.Index(inputURL, "/video/")]
+func getTwitterStatusFiles(inputURL string, m *discordgo.Message) ([]*fileItem, error) {
+	tweetID, err := getTwitterStatusID(inputURL)
+	if err != nil {
+		return nil, err
 	}
-
-	matches := regexUrlTwitterStatus.FindStringSubmatch(inputURL)
-	_, err := strconv.ParseInt(matches[4], 10, 64)
+	tweet, err := twitterScraper.GetTweet(tweetID)
 	if err != nil {
 		return nil, err
 	}
-	tweet, err := twitterScraper.GetTweet(matches[4])
+	return twitterThreadFiles(twitterPostFromScraper(tweet), getTwitterScraperPost, m), nil
+}
+
+func getTwitterScraperPost(tweetID string) (*twitterPost, error) {
+	tweet, err := twitterScraper.GetTweet(tweetID)
 	if err != nil {
 		return nil, err
 	}
+	return twitterPostFromScraper(tweet), nil
+}
 
-	links := make(map[string]string)
+func twitterPostFromScraper(tweet *twitterscraper.Tweet) *twitterPost {
+	post := &twitterPost{
+		ID:        tweet.ID,
+		Author:    tweet.Username,
+		Text:      tweet.Text,
+		Time:      tweet.TimeParsed,
+		QuotedID:  tweet.QuotedStatusID,
+		ReplyToID: tweet.InReplyToStatusID,
+	}
 	for _, photo := range tweet.Photos {
-		foundUrls := getParsedLinks(photo.URL, m)
-		for foundUrlKey, foundUrlValue := range foundUrls {
-			links[foundUrlKey] = foundUrlValue
-		}
+		post.Media = append(post.Media, photo.URL)
 	}
 	for _, video := range tweet.Videos {
-		foundUrls := getParsedLinks(video.URL, m)
-		for foundUrlKey, foundUrlValue := range foundUrls {
-			links[foundUrlKey] = foundUrlValue
-		}
+		post.Media = append(post.Media, video.URL)
 	}
 	for _, gif := range tweet.GIFs {
-		foundUrls := getParsedLinks(gif.URL, m)
-		for foundUrlKey, foundUrlValue := range foundUrls {
-			links[foundUrlKey] = foundUrlValue
-		}
+		post.Media = append(post.Media, gif.URL)
 	}
-
-	return links, nil
+	if tweet.QuotedStatus != nil {
+		post.Quoted = twitterPostFromScraper(tweet.QuotedStatus)
+	}
+	if tweet.InReplyToStatus != nil {
+		post.ReplyTo = twitterPostFromScraper(tweet.InReplyToStatus)
+	}
+	return post
 }
 
 //#endregion
 
 //#region Instagram
 
-func getInstagramUrls(inputURL string, m *discordgo.Message) (map[string]string, error) {
+var (
+	regexUrlInstagram          = regexp.MustCompile(`^http(s?):\/\/(www\.)?instagram\.com\/p\/[^/]+\/(\?[^/]+)?$`)
+	regexUrlInstagramReel      = regexp.MustCompile(`^http(s?):\/\/(www\.)?instagram\.com\/reel\/[^/]+\/(\?[^/]+)?$`)
+	regexUrlInstagramStory     = regexp.MustCompile(`^http(s?):\/\/(www\.)?instagram\.com\/stories\/([A-Za-z0-9_\.]+)(\/([0-9]+))?\/?(\?[^/]+)?$`)
+	regexUrlInstagramHighlight = regexp.MustCompile(`^http(s?):\/\/(www\.)?instagram\.com\/stories\/highlights\/([0-9]+)\/?(\?[^/]+)?$`)
+	regexUrlInstagramShare     = regexp.MustCompile(`^http(s?):\/\/(www\.)?instagram\.com\/s\/([A-Za-z0-9_\-=%]+)\/?(\?[^/]+)?$`)
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "instagram",
+		routes: []extractorRoute{
+			{regexUrlInstagram, getInstagramPostFiles},
+			{regexUrlInstagramReel, getInstagramPostFiles},
+			{regexUrlInstagramHighlight, getInstagramHighlightFiles},
+			{regexUrlInstagramShare, getInstagramHighlightFiles},
+			{regexUrlInstagramStory, getInstagramStoryFiles},
+		},
+		needsAuth: func() bool { return !instagramConnected },
+	})
+}
+
+func getInstagramPostFiles(inputURL string, m *discordgo.Message) ([]*fileItem, error) {
+	if strings.Contains(inputURL, "?") {
+		inputURL = inputURL[:strings.Index(inputURL, "?")]
+	}
 	if instagramClient == nil {
 		return nil, errors.New("invalid Instagram API credentials")
 	}
 
-	links := make(map[string]string)
-
 	// fix
 	shortcode := inputURL
 	if strings.Contains(shortcode, ".com/p/") {
@@ -326,40 +375,215 @@ func getInstagramUrls(inputURL string, m *discordgo.Message) (map[string]string,
 
 	// fetch
 	mediaID, err := goinsta.MediaIDFromShortID(shortcode)
-	if err == nil {
-		media, err := instagramClient.GetMedia(mediaID)
+	if err != nil {
+		return nil, nil
+	}
+	media, err := instagramClient.GetMedia(mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if len(media.Items) == 0 {
+		return nil, nil
+	}
+	return instagramItemFiles(media.Items[0], shortcode), nil
+}
+
+// Stories by the user that are still up, just the one when the link has its ID.
+func getInstagramStoryFiles(inputURL string, m *discordgo.Message) ([]*fileItem, error) {
+	if instagramClient == nil {
+		return nil, errors.New("invalid Instagram API credentials")
+	}
+	matches := regexUrlInstagramStory.FindStringSubmatch(inputURL)
+	username, storyID := matches[3], matches[5]
+	if username == "highlights" {
+		return nil, nil
+	}
+
+	user, err := instagramClient.Profiles.ByName(username)
+	if err != nil {
+		return nil, err
+	}
+	stories, err := user.Stories()
+	if err != nil {
+		return nil, err
+	}
+	var items []*fileItem
+	for _, story := range stories.Reel.Items {
+		pk := strconv.FormatInt(story.Pk, 10)
+		if storyID == "" || storyID == pk {
+			items = append(items, instagramItemFiles(story, pk)...)
+		}
+	}
+
+	// Expired stories can still be fetched as media while they're archived or in a highlight
+	if len(items) == 0 && storyID != "" {
+		media, err := instagramClient.GetMedia(storyID)
 		if err != nil {
 			return nil, err
-		} else {
-			postType := media.Items[0].MediaToString()
-			if postType == "carousel" {
-				for index, item := range media.Items[0].CarouselMedia {
-					itemType := item.MediaToString()
-					if itemType == "video" {
-						url := item.Videos[0].URL
-						links[url] = fmt.Sprintf("%s %d %s", shortcode, index, media.Items[0].User.Username)
-					} else if itemType == "photo" {
-						url := item.Images.GetBest()
-						links[url] = fmt.Sprintf("%s %d %s", shortcode, index, media.Items[0].User.Username)
-					}
-				}
-			} else if postType == "video" {
-				url := media.Items[0].Videos[0].URL
-				links[url] = fmt.Sprintf("%s %s", shortcode, media.Items[0].User.Username)
-			} else if postType == "photo" {
-				url := media.Items[0].Images.GetBest()
-				links[url] = fmt.Sprintf("%s %s", shortcode, media.Items[0].User.Username)
-			}
+		}
+		for _, story := range media.Items {
+			items = append(items, instagramItemFiles(story, storyID)...)
 		}
 	}
+	return items, nil
+}
 
-	return links, nil
+// Highlight links, including the /s/ share links the app makes for them.
+func getInstagramHighlightFiles(inputURL string, m *discordgo.Message) ([]*fileItem, error) {
+	if instagramClient == nil {
+		return nil, errors.New("invalid Instagram API credentials")
+	}
+	highlightID := ""
+	if matches := regexUrlInstagramHighlight.FindStringSubmatch(inputURL); matches != nil {
+		highlightID = matches[3]
+	} else if matches := regexUrlInstagramShare.FindStringSubmatch(inputURL); matches != nil {
+		// Base64 of the reel ID, "highlight:<id>"
+		code, _ := url.PathUnescape(matches[3])
+		decoded, err := base64.StdEncoding.DecodeString(code)
+		if err != nil {
+			decoded, _ = base64.RawURLEncoding.DecodeString(strings.TrimRight(code, "="))
+		}
+		if !strings.HasPrefix(string(decoded), "highlight:") {
+			return nil, nil
+		}
+		highlightID = strings.TrimPrefix(string(decoded), "highlight:")
+	}
+	if highlightID == "" {
+		return nil, nil
+	}
+
+	// goinsta only fetches highlights through the reels of a user it's looked up, so the reel media
+	// endpoint is asked directly
+	reelID := "highlight:" + highlightID
+	var response struct {
+		Reels map[string]struct {
+			Items []*goinsta.Item `json:"items"`
+		} `json:"reels"`
+	}
+	if err := getInstagramAPI(requestContext(m), "feed/reels_media/?reel_ids="+url.QueryEscape(reelID), &response); err != nil {
+		return nil, err
+	}
+	highlight, found := response.Reels[reelID]
+	if !found {
+		return nil, fmt.Errorf("highlight %s not found", highlightID)
+	}
+
+	var items []*fileItem
+	for _, story := range highlight.Items {
+		items = append(items, instagramItemFiles(story, strconv.FormatInt(story.Pk, 10))...)
+	}
+	return items, nil
+}
+
+var instagramAPIBase = "https://i.instagram.com/api/v1/"
+
+// Instagram's Android app, which goinsta signs in as
+const (
+	instagramAppID      = "567067343352427"
+	instagramAppVersion = "250.0.0.21.109"
+	instagramAppCode    = "394071253"
+)
+
+// getInstagramAPI makes a request to the app's API as the signed in session, for what goinsta has no call for.
+func getInstagramAPI(ctx context.Context, endpoint string, target interface{}) error {
+	session := instagramClient.ExportConfig()
+	device := session.Device
+	headers := map[string]string{
+		"User-Agent": fmt.Sprintf("Instagram %s Android (%d/%d; %s; %s; %s; %s; %s; %s; en_US; %s)",
+			instagramAppVersion, device.AndroidVersion, device.AndroidRelease, device.ScreenDpi, device.ScreenResolution,
+			device.Manufacturer, device.Model, device.CodeName, device.Chipset, instagramAppCode),
+		"X-Ig-App-Id":         instagramAppID,
+		"Ig-Intended-User-Id": strconv.FormatInt(session.ID, 10),
+	}
+	for key, value := range session.HeaderOptions { // authorization & such
+		headers[key] = value
+	}
+	return getJSONwithHeaders(ctx, instagramAPIBase+endpoint, target, headers)
+}
+
+// instagramItemFiles gives every file of a post or story at its largest size, named
+// "<code> <username>" or "<code> <index> <username>" for each item of a carousel.
+func instagramItemFiles(item *goinsta.Item, code string) []*fileItem {
+	var posted time.Time
+	date := ""
+	if item.TakenAt > 0 {
+		posted = time.Unix(item.TakenAt, 0)
+		date = posted.Format(config.FilenameDateFormat)
+	}
+
+	var items []*fileItem
+	add := func(media *goinsta.Item, filename string) {
+		link := ""
+		switch media.MediaToString() {
+		case "video":
+			link = largestInstagramVideo(media.Videos)
+		case "photo":
+			link = largestInstagramImage(media.Images)
+		}
+		if link == "" {
+			return
+		}
+		items = append(items, &fileItem{
+			Link:     link,
+			Filename: filename,
+			PostTime: posted,
+			DataKeys: map[string]string{
+				"instagramAuthor": item.User.Username,
+				"instagramPostID": code,
+				"instagramDate":   date,
+			},
+		})
+	}
+	if item.MediaToString() == "carousel" {
+		for index := range item.CarouselMedia {
+			add(&item.CarouselMedia[index], fmt.Sprintf("%s %d %s", code, index, item.User.Username))
+		}
+	} else {
+		add(item, fmt.Sprintf("%s %s", code, item.User.Username))
+	}
+	return items
+}
+
+// Images.GetBest takes any later candidate that is bigger in one dimension, even when smaller overall.
+func largestInstagramImage(images goinsta.Images) string {
+	best, bestArea := "", -1
+	for _, candidate := range images.Versions {
+		if area := candidate.Width * candidate.Height; area > bestArea {
+			best, bestArea = candidate.URL, area
+		}
+	}
+	return best
+}
+
+func largestInstagramVideo(videos []goinsta.Video) string {
+	best, bestArea := "", -1
+	for _, video := range videos {
+		if area := video.Width * video.Height; area > bestArea {
+			best, bestArea = video.URL, area
+		}
+	}
+	return best
 }
 
 //#endregion
 
 //#region Imgur
 
+var (
+	regexUrlImgurSingle = regexp.MustCompile(`^http(s?):\/\/(i\.)?imgur\.com\/[A-Za-z0-9]+(\.gifv)?$`)
+	regexUrlImgurAlbum  = regexp.MustCompile(`^http(s?):\/\/imgur\.com\/(a\/|gallery\/|r\/[^\/]+\/)[A-Za-z0-9]+(#[A-Za-z0-9]+)?$`)
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "imgur",
+		routes: []extractorRoute{
+			{regexUrlImgurSingle, ignoreMessage(getImgurSingleUrls)},
+			{regexUrlImgurAlbum, withLinks(getImgurAlbumUrls)},
+		},
+	})
+}
+
 func getImgurSingleUrls(url string) (map[string]string, error) {
 	url = regexp.MustCompile(`(r\/[^\/]+\/)`).ReplaceAllString(url, "") // remove subreddit url
 	url = strings.Replace(url, "imgur.com/", "imgur.com/download/", -1)
@@ -373,14 +597,14 @@ type imgurAlbumObject struct {
 	}
 }
 
-func getImgurAlbumUrls(url string) (map[string]string, error) {
+func getImgurAlbumUrls(url string, m *discordgo.Message) (map[string]string, error) {
 	url = regexp.MustCompile(`(#[A-Za-z0-9]+)?$`).ReplaceAllString(url, "") // remove anchor
 	afterLastSlash := strings.LastIndex(url, "/")
 	albumId := url[afterLastSlash+1:]
 	headers := make(map[string]string)
 	headers["Authorization"] = "Client-ID " + imgurClientID
 	imgurAlbumObject := new(imgurAlbumObject)
-	getJSONwithHeaders("https://api.imgur.com/3/album/"+albumId+"/images", imgurAlbumObject, headers)
+	getJSONwithHeaders(requestContext(m), "https://api.imgur.com/3/album/"+albumId+"/images", imgurAlbumObject, headers)
 	links := make(map[string]string)
 	for _, v := range imgurAlbumObject.Data {
 		links[v.Link] = ""
@@ -396,6 +620,15 @@ func getImgurAlbumUrls(url string) (map[string]string, error) {
 
 //#region Streamable
 
+var regexUrlStreamable = regexp.MustCompile(`^http(s?):\/\/(www\.)?streamable\.com\/([0-9a-z]+)$`)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name:   "streamable",
+		routes: []extractorRoute{{regexUrlStreamable, withLinks(getStreamableUrls)}},
+	})
+}
+
 type streamableObject struct {
 	Status int    `json:"status"`
 	Title  string `json:"title"`
@@ -416,7 +649,7 @@ type streamableObject struct {
 	Message      interface{} `json:"message"`
 }
 
-func getStreamableUrls(url string) (map[string]string, error) {
+func getStreamableUrls(url string, m *discordgo.Message) (map[string]string, error) {
 	matches := regexUrlStreamable.FindStringSubmatch(url)
 	shortcode := matches[3]
 	if shortcode == "" {
@@ -424,7 +657,7 @@ func getStreamableUrls(url string) (map[string]string, error) {
 	}
 	reqUrl := fmt.Sprintf("https://api.streamable.com/videos/%s", shortcode)
 	streamable := new(streamableObject)
-	getJSON(reqUrl, streamable)
+	getJSON(requestContext(m), reqUrl, streamable)
 	if streamable.Status != 2 || streamable.Files.Mp4.URL == "" {
 		return nil, errors.New("streamable object has no download candidate")
 	}
@@ -441,20 +674,29 @@ func getStreamableUrls(url string) (map[string]string, error) {
 
 //#region Gfycat
 
+var regexUrlGfycat = regexp.MustCompile(`^http(s?):\/\/gfycat\.com\/(gifs\/detail\/)?[A-Za-z]+$`)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name:   "gfycat",
+		routes: []extractorRoute{{regexUrlGfycat, withLinks(getGfycatUrls)}},
+	})
+}
+
 type gfycatObject struct {
 	GfyItem struct {
 		Mp4URL string `json:"mp4Url"`
 	} `json:"gfyItem"`
 }
 
-func getGfycatUrls(url string) (map[string]string, error) {
+func getGfycatUrls(url string, m *discordgo.Message) (map[string]string, error) {
 	parts := strings.Split(url, "/")
 	if len(parts) < 3 {
 		return nil, errors.New("unable to parse Gfycat URL")
 	}
 	gfycatId := parts[len(parts)-1]
 	gfycatObject := new(gfycatObject)
-	getJSON("https://api.gfycat.com/v1/gfycats/"+gfycatId, gfycatObject)
+	getJSON(requestContext(m), "https://api.gfycat.com/v1/gfycats/"+gfycatId, gfycatObject)
 	gfycatUrl := gfycatObject.GfyItem.Mp4URL
 	if url == "" {
 		return nil, errors.New("failed to read response from Gfycat")
@@ -466,6 +708,23 @@ func getGfycatUrls(url string) (map[string]string, error) {
 
 //#region Flickr
 
+var (
+	regexUrlFlickrPhoto      = regexp.MustCompile(`^http(s)?:\/\/(www\.)?flickr\.com\/photos\/([0-9]+)@([A-Z0-9]+)\/([0-9]+)(\/)?(\/in\/album-([0-9]+)(\/)?)?$`)
+	regexUrlFlickrAlbum      = regexp.MustCompile(`^http(s)?:\/\/(www\.)?flickr\.com\/photos\/(([0-9]+)@([A-Z0-9]+)|[A-Za-z0-9]+)\/(albums\/(with\/)?|(sets\/)?)([0-9]+)(\/)?$`)
+	regexUrlFlickrAlbumShort = regexp.MustCompile(`^http(s)?:\/\/((www\.)?flickr\.com\/gp\/[0-9]+@[A-Z0-9]+\/[A-Za-z0-9]+|flic\.kr\/s\/[a-zA-Z0-9]+)$`)
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "flickr",
+		routes: []extractorRoute{
+			{regexUrlFlickrPhoto, withLinks(getFlickrPhotoUrls)},
+			{regexUrlFlickrAlbum, withLinks(getFlickrAlbumUrls)},
+			{regexUrlFlickrAlbumShort, withLinks(getFlickrAlbumShortUrls)},
+		},
+	})
+}
+
 type flickrPhotoSizeObject struct {
 	Label  string `json:"label"`
 	Width  int    `json:"width"`
@@ -485,11 +744,11 @@ type flickrPhotoObject struct {
 	Stat string `json:"stat"`
 }
 
-func getFlickrUrlFromPhotoId(photoId string) string {
+func getFlickrUrlFromPhotoId(ctx context.Context, photoId string) string {
 	reqUrl := fmt.Sprintf("https://www.flickr.com/services/rest/?format=json&nojsoncallback=1&method=%s&api_key=%s&photo_id=%s",
 		"flickr.photos.getSizes", config.Credentials.FlickrApiKey, photoId)
 	flickrPhoto := new(flickrPhotoObject)
-	getJSON(reqUrl, flickrPhoto)
+	getJSON(ctx, reqUrl, flickrPhoto)
 	var bestSize flickrPhotoSizeObject
 	for _, size := range flickrPhoto.Sizes.Size {
 		if bestSize.Label == "" {
@@ -503,7 +762,7 @@ func getFlickrUrlFromPhotoId(photoId string) string {
 	return bestSize.Source
 }
 
-func getFlickrPhotoUrls(url string) (map[string]string, error) {
+func getFlickrPhotoUrls(url string, m *discordgo.Message) (map[string]string, error) {
 	if config.Credentials.FlickrApiKey == "" {
 		return nil, errors.New("invalid Flickr API Key Set")
 	}
@@ -512,7 +771,7 @@ func getFlickrPhotoUrls(url string) (map[string]string, error) {
 	if photoId == "" {
 		return nil, errors.New("unable to get Photo ID from URL")
 	}
-	return map[string]string{getFlickrUrlFromPhotoId(photoId): ""}, nil
+	return map[string]string{getFlickrUrlFromPhotoId(requestContext(m), photoId): ""}, nil
 }
 
 type flickrAlbumObject struct {
@@ -542,7 +801,7 @@ type flickrAlbumObject struct {
 	Stat string `json:"stat"`
 }
 
-func getFlickrAlbumUrls(url string) (map[string]string, error) {
+func getFlickrAlbumUrls(url string, m *discordgo.Message) (map[string]string, error) {
 	if config.Credentials.FlickrApiKey == "" {
 		return nil, errors.New("invalid Flickr API Key Set")
 	}
@@ -556,22 +815,28 @@ func getFlickrAlbumUrls(url string) (map[string]string, error) {
 	}
 	reqUrl := fmt.Sprintf("https://www.flickr.com/services/rest/?format=json&nojsoncallback=1&method=%s&api_key=%s&photoset_id=%s&per_page=500",
 		"flickr.photosets.getPhotos", config.Credentials.FlickrApiKey, albumId)
+	ctx := requestContext(m)
 	flickrAlbum := new(flickrAlbumObject)
-	getJSON(reqUrl, flickrAlbum)
+	getJSON(ctx, reqUrl, flickrAlbum)
 	links := make(map[string]string)
 	for _, photo := range flickrAlbum.Photoset.Photo {
-		links[getFlickrUrlFromPhotoId(photo.ID)] = ""
+		links[getFlickrUrlFromPhotoId(ctx, photo.ID)] = ""
 	}
 	return links, nil
 }
 
-func getFlickrAlbumShortUrls(url string) (map[string]string, error) {
-	result, err := http.Get(url)
+func getFlickrAlbumShortUrls(url string, m *discordgo.Message) (map[string]string, error) {
+	request, err := http.NewRequestWithContext(requestContext(m), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := doRequest(request)
 	if err != nil {
 		return nil, errors.New("Error getting long URL from shortened Flickr Album URL: " + err.Error())
 	}
+	result.Body.Close()
 	if regexUrlFlickrAlbum.MatchString(result.Request.URL.String()) {
-		return getFlickrAlbumUrls(result.Request.URL.String())
+		return getFlickrAlbumUrls(result.Request.URL.String(), m)
 	}
 	return nil, errors.New("encountered invalid URL while trying to get long URL from short Flickr Album URL")
 }
@@ -580,6 +845,29 @@ func getFlickrAlbumShortUrls(url string) (map[string]string, error) {
 
 //#region Tistory
 
+var (
+	regexUrlTistory              = regexp.MustCompile(`^http(s?):\/\/t[0-9]+\.daumcdn\.net\/cfile\/tistory\/([A-Z0-9]+?)(\?original)?$`)
+	regexUrlTistoryLegacy        = regexp.MustCompile(`^http(s?):\/\/[a-z0-9]+\.uf\.tistory\.com\/(image|original)\/[A-Z0-9]+$`)
+	regexUrlTistoryLegacyWithCDN = regexp.MustCompile(`^http(s)?:\/\/[0-9a-z]+.daumcdn.net\/[a-z]+\/[a-zA-Z0-9\.]+\/\?scode=mtistory&fname=http(s?)%3A%2F%2F[a-z0-9]+\.uf\.tistory\.com%2F(image|original)%2F[A-Z0-9]+$`)
+	regexUrlPossibleTistorySite  = regexp.MustCompile(`^http(s)?:\/\/[0-9a-zA-Z\.-]+\/(m\/)?(photo\/)?[0-9]+$`)
+)
+
+func init() {
+	registerExtractor(&siteExtractor{
+		name: "tistory",
+		routes: []extractorRoute{
+			{regexUrlTistory, ignoreMessage(getTistoryUrls)},
+			{regexUrlTistoryLegacy, ignoreMessage(getLegacyTistoryUrls)},
+		},
+	})
+	// Matches any numbered page on any site, so it only gets a look once nothing else wanted the link.
+	// The original project has this as an option,
+	registerFallbackExtractor(&siteExtractor{
+		name:   "tistory-site",
+		routes: []extractorRoute{{regexUrlPossibleTistorySite, withLinks(getPossibleTistorySiteUrls)}},
+	})
+}
+
 // getTistoryUrls downloads tistory URLs
 // http://t1.daumcdn.net/cfile/tistory/[…] => http://t1.daumcdn.net/cfile/tistory/[…]
 // http://t1.daumcdn.net/cfile/tistory/[…]?original => as is
@@ -607,19 +895,20 @@ func getTistoryWithCDNUrls(urlI string) (map[string]string, error) {
 	return nil, nil
 }
 
-func getPossibleTistorySiteUrls(url string) (map[string]string, error) {
-	client := new(http.Client)
-	request, err := http.NewRequest("HEAD", url, nil)
+func getPossibleTistorySiteUrls(url string, m *discordgo.Message) (map[string]string, error) {
+	ctx := requestContext(m)
+	request, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Add("Accept-Encoding", "identity")
-	request.Header.Add("User-Agent", sneakyUserAgent)
-	respHead, err := client.Do(request)
+	respHead, err := doRequest(request)
 	if err != nil {
 		return nil, err
 	}
 
+	respHead.Body.Close()
+
 	contentType := ""
 	for headerKey, headerValue := range respHead.Header {
 		if headerKey == "Content-Type" {
@@ -630,18 +919,18 @@ func getPossibleTistorySiteUrls(url string) (map[string]string, error) {
 		return nil, nil
 	}
 
-	request, err = http.NewRequest("GET", url, nil)
+	request, err = http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Add("Accept-Encoding", "identity")
-	request.Header.Add("User-Agent", sneakyUserAgent)
-	resp, err := client.Do(request)
+	resp, err := doRequest(request)
 	if err != nil {
 		return nil, err
 	}
 
 	doc, err := goquery.NewDocumentFromResponse(resp)
+	resp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -678,38 +967,3 @@ func getPossibleTistorySiteUrls(url string) (map[string]string, error) {
 }
 
 //#endregion
-
-//#region Reddit
-
-// This is very crude but works for now
-type redditThreadObject []struct {
-	Kind string `json:"kind"`
-	Data struct {
-		Children interface{} `json:"children"`
-	} `json:"data"`
-}
-
-func getRedditPostUrls(link string) (map[string]string, error) {
-	if strings.Contains(link, "?") {
-		link = link[:strings.Index(link, "?")]
-	}
-	redditThread := new(redditThreadObject)
-	headers := make(map[string]string)
-	headers["Accept-Encoding"] = "identity"
-	headers["User-Agent"] = sneakyUserAgent
-	err := getJSONwithHeaders(link+".json", redditThread, headers)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse json from reddit post:\t%s", err)
-	}
-
-	redditPost := (*redditThread)[0].Data.Children.([]interface{})[0].(map[string]interface{})
-	redditPostData := redditPost["data"].(map[string]interface{})
-	if redditPostData["url_overridden_by_dest"] != nil {
-		redditLink := redditPostData["url_overridden_by_dest"].(string)
-		filename := fmt.Sprintf("Reddit-%s_%s %s", redditPostData["subreddit"].(string), redditPostData["id"].(string), filenameFromURL(redditLink))
-		return map[string]string{redditLink: filename}, nil
-	}
-	return nil, nil
-}
-
-//#endregion
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInstagramHighlightFiles(t *testing.T) {
+	source := useTestSource(setupTestDownloads(t))
+	var gotQuery, gotAuth, gotAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery, gotAuth, gotAgent = r.URL.RawQuery, r.Header.Get("Authorization"), r.Header.Get("User-Agent")
+		w.Write([]byte(`{"reels":{"highlight:17900000000000001":{"items":[
+			{"pk":11,"taken_at":1700000000,"media_type":1,"user":{"username":"someone"},
+			 "image_versions2":{"candidates":[{"width":320,"height":320,"url":"https://cdn.test/small.jpg"},{"width":1080,"height":1920,"url":"https://cdn.test/full.jpg"}]}},
+			{"pk":12,"media_type":2,"user":{"username":"someone"},
+			 "video_versions":[{"width":720,"height":1280,"url":"https://cdn.test/clip.mp4"}]}
+		]}},"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	previousBase, previousClient := instagramAPIBase, instagramClient
+	instagramAPIBase = server.URL + "/api/v1/"
+	instagramClient = goinsta.New("someone", "password")
+	instagramClient.Account = &goinsta.Account{ID: 5}
+	t.Cleanup(func() { instagramAPIBase, instagramClient = previousBase, previousClient })
+	// A signed in session, as imported from the cache
+	bearer := "Bearer IGT:2:session"
+	session := instagramClient.ExportConfig()
+	session.HeaderOptions["Authorization"] = bearer
+	instagramClient, _ = goinsta.ImportConfig(session, true)
+
+	m := &discordgo.Message{ID: "200", ChannelID: source.ChannelID}
+	share := "https://www.instagram.com/s/" + base64.StdEncoding.EncodeToString([]byte("highlight:17900000000000001")) + "/"
+	for _, link := range []string{"https://www.instagram.com/stories/highlights/17900000000000001/", share} {
+		items, err := getInstagramHighlightFiles(link, m)
+		if err != nil {
+			t.Fatalf("%s: %v", link, err)
+		}
+		if len(items) != 2 || items[0].Link != "https://cdn.test/full.jpg" || items[1].Link != "https://cdn.test/clip.mp4" {
+			t.Errorf("%s: items = %+v", link, items)
+		}
+	}
+	if gotQuery != "reel_ids=highlight%3A17900000000000001" || gotAuth != bearer || gotAgent == "" {
+		t.Errorf("request query %q, authorization %q, user agent %q", gotQuery, gotAuth, gotAgent)
+	}
+
+	if _, err := getInstagramHighlightFiles("https://www.instagram.com/stories/highlights/1/", m); err == nil {
+		t.Error("missing highlight didn't error")
+	}
+}
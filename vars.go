@@ -20,6 +20,7 @@ var (
 	pathCacheTwitter      = pathCache + string(os.PathSeparator) + "twitter.json"
 	pathCacheInstagram    = pathCache + string(os.PathSeparator) + "instagram.json"
 	pathConstants         = pathCache + string(os.PathSeparator) + "constants.json"
+	pathCachePartials     = pathCache + string(os.PathSeparator) + "partials"
 	pathDatabaseBase      = "database"
 	pathDatabaseBackups   = "backups"
 